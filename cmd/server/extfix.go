@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+	"github.com/mtiwari1/gopherdrive/internal/worker"
+)
+
+// preferredExt picks the conventional extension where mime.ExtensionsByType
+// offers several (it returns them alphabetically, e.g. ".jfif" before ".jpg")
+// or, depending on the host's MIME database, none.
+var preferredExt = map[string]string{
+	"text/plain":      ".txt",
+	"text/html":       ".html",
+	"text/xml":        ".xml",
+	"application/zip": ".zip",
+	"image/jpeg":      ".jpg",
+	"image/tiff":      ".tiff",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"video/mp4":       ".mp4",
+	"video/mpeg":      ".mpeg",
+	"video/quicktime": ".mov",
+}
+
+// correctedExt returns the extension the detected mimeType calls for, or ""
+// when the current ext already fits or the sniffed type is too generic to
+// trust (text/plain covers .md, .csv, .go…; zip covers .docx, .jar…).
+func correctedExt(mimeType, ext string) string {
+	// Without an extension there is nothing to contradict, so any
+	// recognised type will do.
+	if ext == "" {
+		return inferredExt(mimeType)
+	}
+	mt, _, _ := strings.Cut(mimeType, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	top, _, _ := strings.Cut(mt, "/")
+	if top != "image" && top != "audio" && top != "video" && mt != "application/pdf" {
+		return ""
+	}
+
+	exts, err := mime.ExtensionsByType(mt)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	for _, e := range exts {
+		if strings.EqualFold(e, ext) {
+			return ""
+		}
+	}
+	if e, ok := preferredExt[mt]; ok {
+		return e
+	}
+	return exts[0]
+}
+
+// inferredExt returns the conventional extension for mimeType, or "" when
+// the type is unknown or too vague to name (application/octet-stream).
+func inferredExt(mimeType string) string {
+	mt, _, _ := strings.Cut(mimeType, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	if mt == "" || mt == "application/octet-stream" {
+		return ""
+	}
+	if e, ok := preferredExt[mt]; ok {
+		return e
+	}
+	exts, err := mime.ExtensionsByType(mt)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
+
+// storeWithCorrectedExt handles a result whose detected MIME type contradicts
+// the stored extension: it links the file under the corrected name, then
+// updates file_path and completes processing in one transaction. The old name is removed
+// only after the commit, so the record never points at a missing file. It
+// returns the new extension, or "" without writing anything when no
+// correction applies.
+func storeWithCorrectedExt(ctx context.Context, repo repository.Repository, res worker.Result) (string, error) {
+	// A type inferred from the extension cannot contradict it.
+	if sniffed, ok := res.Metadata["mime_sniffed"].(bool); ok && !sniffed {
+		return "", nil
+	}
+	mimeType, _ := res.Metadata["mime_type"].(string)
+	oldExt := filepath.Ext(res.FilePath)
+	newExt := correctedExt(mimeType, oldExt)
+	if res.FilePath == "" || newExt == "" {
+		return "", nil
+	}
+
+	newPath := strings.TrimSuffix(res.FilePath, oldExt) + newExt
+	// This is not a rename: the file is linked under the new name, the record
+	// is pointed at it in a transaction, and only then is the old name
+	// unlinked. Link never replaces an existing file, and at every step at
+	// least the name the record holds exists. A crash after the link leaves
+	// the unreferenced name behind: the new one if the transaction did not
+	// commit, the old one if it did.
+	if err := os.Link(res.FilePath, newPath); err != nil {
+		return "", fmt.Errorf("link corrected path: %w", err)
+	}
+
+	meta := make(map[string]interface{}, len(res.Metadata)+2)
+	for k, v := range res.Metadata {
+		meta[k] = v
+	}
+	meta["original_extension"] = oldExt
+	meta["corrected_extension"] = newExt
+
+	err := repo.WithTx(ctx, func(tx repository.RepositoryTx) error {
+		if err := tx.UpdateFilePath(ctx, res.FileID, newPath); err != nil {
+			return err
+		}
+		return tx.CompleteProcessing(ctx, res.FileID, res.Epoch, res.Hash, res.Size, meta)
+	})
+	if err != nil {
+		os.Remove(newPath)
+		return "", err
+	}
+
+	os.Remove(res.FilePath)
+	return newExt, nil
+}
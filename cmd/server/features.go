@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// featureFlag is an optional behaviour that can be switched on or off from
+// FEATURES as well as from its own environment variable.
+type featureFlag struct {
+	env string // individual override, e.g. FIX_EXTENSIONS
+	def bool
+}
+
+// featureFlags lists every toggleable feature by its FEATURES name.
+var featureFlags = map[string]featureFlag{
+	"fix_extensions":          {"FIX_EXTENSIONS", false},
+	"quarantine_executables":  {"QUARANTINE_EXECUTABLES", false},
+	"mime_extension_fallback": {"MIME_EXTENSION_FALLBACK", true},
+	"strict_metadata":         {"STRICT_METADATA", false},
+	"leader_election":         {"LEADER_ELECTION", false},
+	"serve_static":            {"SERVE_STATIC", true},
+	"upload_any_field":        {"UPLOAD_ANY_FIELD", false},
+}
+
+// features holds the resolved state of every feature flag.
+type features map[string]bool
+
+// loadFeatures resolves the flags from spec, a comma-separated FEATURES
+// value such as "fix_extensions,-serve_static" where a leading "-" turns a
+// feature off. Unlisted features keep their defaults, and a feature's own
+// environment variable, when set, overrides both. Unknown names are an
+// error so a typo cannot silently leave a feature off.
+func loadFeatures(spec string) (features, error) {
+	f := make(features, len(featureFlags))
+	for name, flag := range featureFlags {
+		f[name] = flag.def
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, on := strings.CutPrefix(entry, "-")
+		if _, ok := featureFlags[name]; !ok {
+			return nil, fmt.Errorf("FEATURES: unknown feature %q", name)
+		}
+		f[name] = !on
+	}
+	for name, flag := range featureFlags {
+		f[name] = envBoolOrDefault(flag.env, f[name])
+	}
+	return f, nil
+}
+
+// enabled returns the names of the features that are on, sorted.
+func (f features) enabled() []string {
+	var names []string
+	for name, on := range f {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -6,23 +6,40 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/mtiwari1/gopherdrive/internal/diskguard"
+	"github.com/mtiwari1/gopherdrive/internal/eventlog"
+	"github.com/mtiwari1/gopherdrive/internal/fdlimit"
 	grpcserver "github.com/mtiwari1/gopherdrive/internal/grpcserver"
+	"github.com/mtiwari1/gopherdrive/internal/idgen"
+	"github.com/mtiwari1/gopherdrive/internal/leader"
+	"github.com/mtiwari1/gopherdrive/internal/metaschema"
+	"github.com/mtiwari1/gopherdrive/internal/mimestats"
 	"github.com/mtiwari1/gopherdrive/internal/repository"
 	"github.com/mtiwari1/gopherdrive/internal/restapi"
+	"github.com/mtiwari1/gopherdrive/internal/settings"
+	"github.com/mtiwari1/gopherdrive/internal/sweeper"
 	"github.com/mtiwari1/gopherdrive/internal/worker"
 	pb "github.com/mtiwari1/gopherdrive/proto"
+	"github.com/mtiwari1/gopherdrive/web"
 )
 
 const (
@@ -30,6 +47,13 @@ const (
 	grpcPort   = ":50051"
 	httpPort   = ":8080"
 	uploadDir  = "./data"
+
+	// healthCheckInterval controls how often the DB is pinged to drive the
+	// gRPC health status.
+	healthCheckInterval = 10 * time.Second
+
+	// janitorBatch caps how many expired files are removed per sweep.
+	janitorBatch = 100
 )
 
 func main() {
@@ -39,6 +63,14 @@ func main() {
 
 	logger.Info("starting GopherDrive")
 
+	// ── Feature flags ──
+	feats, err := loadFeatures(os.Getenv("FEATURES"))
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	logger.Info("features", slog.Any("enabled", feats.enabled()))
+
 	// ── Ensure upload directory exists ──
 	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
 		logger.Error("create upload dir", slog.String("error", err.Error()))
@@ -54,10 +86,30 @@ func main() {
 	}
 	defer db.Close()
 
+	latencyBuckets, err := worker.ParseLatencyBuckets(os.Getenv("LATENCY_BUCKETS"))
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
 	// Connection pool tuning.
-	db.SetConnMaxLifetime(5 * time.Minute)
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
+	dbPool := dbPoolConfig{
+		MaxOpenConns:    envIntOrDefault("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    envIntOrDefault("DB_MAX_IDLE_CONNS", 25),
+		ConnMaxLifetime: envDurationOrDefault("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+		ConnMaxIdleTime: envDurationOrDefault("DB_CONN_MAX_IDLE_TIME", 0),
+	}
+	if err := dbPool.validate(); err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	dbPool.apply(db)
+	logger.Info("database pool configured",
+		slog.Int("max_open_conns", dbPool.MaxOpenConns),
+		slog.Int("max_idle_conns", dbPool.MaxIdleConns),
+		slog.Duration("conn_max_lifetime", dbPool.ConnMaxLifetime),
+		slog.Duration("conn_max_idle_time", dbPool.ConnMaxIdleTime),
+	)
 
 	if err := db.Ping(); err != nil {
 		logger.Error("ping database", slog.String("error", err.Error()))
@@ -72,31 +124,325 @@ func main() {
 		os.Exit(1)
 	}
 	defer repo.Close()
+	repo.SetStrictMetadata(feats["strict_metadata"], logger)
+	// MAX_FILE_SIZE and ALLOWED_MIME_TYPES apply to every write path, so
+	// gRPC imports cannot bypass what REST uploads are held to.
+	policy := repository.ContentPolicy{
+		MaxSize:          envInt64OrDefault("MAX_FILE_SIZE", 0),
+		AllowedMIMETypes: repository.ParseMIMEList(os.Getenv("ALLOWED_MIME_TYPES")),
+	}
+	if policy.MaxSize < 0 {
+		logger.Error("invalid config", slog.String("error", "MAX_FILE_SIZE must not be negative"))
+		os.Exit(1)
+	}
+	repo.SetContentPolicy(policy)
+
+	// INSTANCE_ID names this replica in queue claims and leader election.
+	host, _ := os.Hostname()
+	instanceID := envOrDefault("INSTANCE_ID", host)
+
+	// ── Job queue ──
+	// QUEUE_BACKEND=db persists jobs in MySQL so they survive crashes and are
+	// shared by every instance; the default keeps them in the pool's channel.
+	var jobQueue repository.JobQueue
+	backend := envOrDefault("QUEUE_BACKEND", "memory")
+	switch backend {
+	case "memory":
+	case "db":
+		q, err := repository.NewMySQLJobQueue(db, instanceID, envDurationOrDefault("JOB_LEASE", 10*time.Minute))
+		if err != nil {
+			logger.Error("init job queue", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		defer q.Close()
+		jobQueue = q
+	default:
+		logger.Error("invalid config", slog.String("error", fmt.Sprintf("unknown QUEUE_BACKEND %q (want memory or db)", backend)))
+		os.Exit(1)
+	}
+	logger.Info("job queue configured", slog.String("backend", backend))
+
+	// ── Overflow policy ──
+	// OVERFLOW_POLICY decides what an upload does when the in-memory queue is
+	// full: block (wait), reject (503) or spill-to-db (park the job in the
+	// jobs table until the pool has room). The durable backend never
+	// overflows, so the policy only applies to QUEUE_BACKEND=memory.
+	overflow, err := restapi.ParseOverflowPolicy(os.Getenv("OVERFLOW_POLICY"))
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	var spillQueue repository.JobQueue
+	if overflow == restapi.OverflowSpill && jobQueue == nil {
+		q, err := repository.NewMySQLJobQueue(db, instanceID, envDurationOrDefault("JOB_LEASE", 10*time.Minute))
+		if err != nil {
+			logger.Error("init spill queue", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		defer q.Close()
+		spillQueue = q
+	}
+
+	// Per-file processing history served by GET /files/{id}/log.
+	events := eventlog.New(envIntOrDefault("EVENTS_PER_FILE", 50), envIntOrDefault("EVENTS_MAX_FILES", 10000))
+
+	// Shared cap on file handles held by uploads, downloads and hashing, so
+	// bursts queue instead of exhausting the process descriptor limit.
+	fileLimiter := fdlimit.New(envInt64OrDefault("MAX_OPEN_FILES", 512))
+	mimeVolume := mimestats.New()
+	logger.Info("file handle limit configured", slog.Int64("max_open_files", fileLimiter.Stats().Max))
+
+	// ID_FORMAT=uuidv7 gives uploads time-ordered IDs.
+	ids, err := idgen.ByName(envOrDefault("ID_FORMAT", "uuidv4"))
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// Refuse uploads once the upload volume is DISK_HIGH_WATERMARK percent
+	// full, until it drops below DISK_LOW_WATERMARK. 0 disables the guard.
+	diskGuard, err := diskguard.New(uploadDir,
+		float64(envIntOrDefault("DISK_HIGH_WATERMARK", 90)),
+		float64(envIntOrDefault("DISK_LOW_WATERMARK", 85)),
+		logger)
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if diskGuard != nil {
+		if err := diskGuard.Check(); err != nil {
+			logger.Error("init disk guard", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	analysisTimeout := envDurationOrDefault("ANALYSIS_TIMEOUT", 30*time.Second)
+
+	shortHash := envIntOrDefault("SHORT_HASH_LENGTH", 0)
+	if shortHash < 0 {
+		logger.Error("invalid config", slog.String("error", "SHORT_HASH_LENGTH must not be negative"))
+		os.Exit(1)
+	}
+
+	smallFile := envInt64OrDefault("SMALL_FILE_THRESHOLD", 0)
+	largeFile := envInt64OrDefault("LARGE_FILE_THRESHOLD", 0)
+	if smallFile > 0 && largeFile > 0 && smallFile >= largeFile {
+		logger.Error("invalid config", slog.String("error", "SMALL_FILE_THRESHOLD must be below LARGE_FILE_THRESHOLD"))
+		os.Exit(1)
+	}
 
 	// ── Worker pool (5 bounded goroutines) ──
-	pool := worker.NewPool(numWorkers, logger)
+	pool := worker.NewPool(numWorkers, logger, worker.Config{
+		AnalysisTimeout:      analysisTimeout,
+		MetricsFlushInterval: envIntervalOrDefault("METRICS_FLUSH_INTERVAL", 5*time.Second),
+		TreeHashThreshold:    envInt64OrDefault("TREE_HASH_THRESHOLD", 0),
+		TreeHashChunkSize:    envInt64OrDefault("TREE_HASH_CHUNK_SIZE", 0),
+		MimeFromExtension:    feats["mime_extension_fallback"],
+		LatencyBuckets:       latencyBuckets,
+		Events:               events,
+		FileLimiter:          fileLimiter,
+		LogSampleRate:        envIntOrDefault("WORKER_LOG_SAMPLE_RATE", 1),
+		SmallFileThreshold:   smallFile,
+		LargeFileThreshold:   largeFile,
+		ShortHashLength:      shortHash,
+	})
 	pool.Start()
 	logger.Info("worker pool started", slog.Int("workers", numWorkers))
 
+	// ── Runtime settings ──
+	// Hot-reloadable tunables stored in the settings table override their
+	// env defaults without a restart (see /admin/settings).
+	settingsRepo, err := repository.NewMySQLSettings(db)
+	if err != nil {
+		logger.Error("init settings", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer settingsRepo.Close()
+	runtimeSettings := settings.New(settingsRepo, logger)
+	runtimeSettings.OnChange(settings.AnalysisTimeout, func() {
+		pool.SetAnalysisTimeout(runtimeSettings.Duration(settings.AnalysisTimeout, analysisTimeout))
+	})
+	runtimeSettings.OnChange(settings.PoolPaused, func() {
+		if runtimeSettings.Bool(settings.PoolPaused, false) {
+			pool.Pause()
+		} else {
+			pool.Resume()
+		}
+	})
+	if err := runtimeSettings.Refresh(context.Background()); err != nil {
+		logger.Warn("load runtime settings, using env defaults", slog.String("error", err.Error()))
+	}
+
+	// Optional JSON Schema that worker metadata is sanitized against before
+	// it is stored.
+	var metaSchema *metaschema.Schema
+	if path := os.Getenv("METADATA_SCHEMA"); path != "" {
+		metaSchema, err = metaschema.Load(path)
+		if err != nil {
+			logger.Error("invalid config", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		logger.Info("metadata schema loaded", slog.String("path", path))
+	}
+
+	// Failure reasons are returned by GET /files/{id}; trim them to keep
+	// long error chains out of the API.
+	maxFailureReason := envIntOrDefault("FAILURE_REASON_MAX_LEN", repository.MaxFailureReasonLen)
+	if maxFailureReason <= 0 || maxFailureReason > repository.MaxFailureReasonLen {
+		logger.Error("invalid config", slog.String("error", fmt.Sprintf("FAILURE_REASON_MAX_LEN must be between 1 and %d", repository.MaxFailureReasonLen)))
+		os.Exit(1)
+	}
+
 	// ── Results handler goroutine ──
 	// Consumes results from the worker pool and updates the database.
+	completionQueue := jobQueue
+	if spillQueue != nil {
+		completionQueue = spillQueue
+	}
 	resultsDone := make(chan struct{})
 	go func() {
 		defer close(resultsDone)
-		handleResults(pool.Results(), repo, logger)
+		rh := &resultHandler{
+			repo:           repo,
+			queue:          completionQueue,
+			fixExt:         feats["fix_extensions"],
+			quarantineExec: feats["quarantine_executables"],
+			schema:         metaSchema,
+			events:         events,
+			logger:         logger,
+			maxReason:      maxFailureReason,
+			volume:         mimeVolume,
+			inlineItems:    envIntOrDefault("METADATA_INLINE_ITEMS", 100),
+			itemSample:     envIntOrDefault("METADATA_ITEM_SAMPLE", 10),
+		}
+		rh.run(pool.Results())
+	}()
+
+	janitorCtx, janitorCancel := context.WithCancel(context.Background())
+
+	// ── Leader election ──
+	// With several replicas, LEADER_ELECTION=true lets only the lease holder
+	// run the singleton janitor and sweeper. A lone instance always leads.
+	isLeader := func() bool { return true }
+	leaderDone := make(chan struct{})
+	if feats["leader_election"] {
+		lock, err := repository.NewMySQLLeaderLock(db)
+		if err != nil {
+			logger.Error("init leader lock", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		defer lock.Close()
+		elector := leader.NewElector(lock, "background-tasks", instanceID, envIntervalOrDefault("LEADER_LEASE", 30*time.Second), logger)
+		isLeader = elector.IsLeader
+		go func() {
+			defer close(leaderDone)
+			elector.Run(janitorCtx)
+		}()
+	} else {
+		close(leaderDone)
+	}
+
+	// ── Retention janitor ──
+	// Periodically removes expired files from disk and the database.
+	// JANITOR_DRY_RUN logs what would be removed instead.
+	janitorDone := make(chan struct{})
+	go func() {
+		defer close(janitorDone)
+		runJanitor(janitorCtx, repo, envIntervalOrDefault("JANITOR_INTERVAL", time.Minute), envBoolOrDefault("JANITOR_DRY_RUN", false), isLeader, logger)
+	}()
+
+	// ── Crash recovery / durable queue ──
+	// With the in-memory queue, re-queue files a previous process accepted but
+	// never finished; the list is taken now, before the REST API accepts
+	// uploads of its own. The durable queue needs no recovery: Feed claims
+	// whatever is queued, including jobs whose claim lease has lapsed.
+	// Spilled jobs are fed once recovery has resubmitted its backlog.
+	recoveryDone := make(chan struct{})
+	var recovery *worker.RecoveryProgress
+	if jobQueue != nil {
+		go func() {
+			defer close(recoveryDone)
+			worker.Feed(janitorCtx, jobQueue, pool, envIntervalOrDefault("QUEUE_POLL_INTERVAL", time.Second), logger)
+		}()
+	} else {
+		pending, err := listPending(context.Background(), repo)
+		if err != nil {
+			logger.Error("recovery list pending", slog.String("error", err.Error()))
+		}
+		// RECOVERY_MAX_QUEUED caps how much of the queue the backlog may
+		// occupy; the default leaves half of it to live uploads.
+		recovery = &worker.RecoveryProgress{}
+		maxQueued := envIntOrDefault("RECOVERY_MAX_QUEUED", numWorkers)
+		go func() {
+			defer close(recoveryDone)
+			if len(pending) > 0 {
+				resubmitPending(janitorCtx, repo, pool, pending, maxQueued, recovery, logger)
+			} else {
+				recovery.Finish()
+			}
+			if spillQueue != nil {
+				worker.Feed(janitorCtx, spillQueue, pool, envIntervalOrDefault("QUEUE_POLL_INTERVAL", time.Second), logger)
+			}
+		}()
+	}
+
+	// ── Artifact sweeper ──
+	// Removes temp files left behind by uploads that died mid-stream.
+	sweep := sweeper.New(envIntervalOrDefault("SWEEP_INTERVAL", 10*time.Minute), logger)
+	sweep.Register("upload_temp_files", sweeper.TempFiles(uploadDir, "upload-*.tmp", envDurationOrDefault("SWEEP_TEMP_MAX_AGE", time.Hour)))
+	sweep.OnlyWhen(isLeader)
+	sweepDone := make(chan struct{})
+	go func() {
+		defer close(sweepDone)
+		sweep.Run(janitorCtx)
+	}()
+
+	settingsDone := make(chan struct{})
+	go func() {
+		defer close(settingsDone)
+		runtimeSettings.Run(janitorCtx, envIntervalOrDefault("SETTINGS_REFRESH_INTERVAL", 30*time.Second))
+	}()
+
+	diskDone := make(chan struct{})
+	go func() {
+		defer close(diskDone)
+		if diskGuard != nil {
+			diskGuard.Run(janitorCtx, envIntervalOrDefault("DISK_CHECK_INTERVAL", 30*time.Second))
+		}
 	}()
 
 	// ── gRPC server ──
 	grpcSrv := grpc.NewServer()
-	grpcImpl := grpcserver.NewServer(repo, logger)
+	metaLimits := metaschema.Limits{
+		MaxKeys:  envIntOrDefault("METADATA_MAX_KEYS", 256),
+		MaxDepth: envIntOrDefault("METADATA_MAX_DEPTH", 8),
+	}
+	grpcImpl := grpcserver.NewServer(repo, logger, grpcserver.Config{
+		MetadataLimits: metaLimits,
+		Events:         events,
+		Requeue:        requeuer(jobQueue, pool),
+	})
 	pb.RegisterGopherDriveServer(grpcSrv, grpcImpl)
 
+	// Standard grpc.health.v1.Health service for gRPC-aware load balancers.
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, healthSrv)
+
 	lis, err := net.Listen("tcp", grpcPort)
 	if err != nil {
 		logger.Error("listen gRPC", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
+	// Dependencies are ready: report SERVING and keep it in sync with the DB.
+	setServingStatus(healthSrv, healthpb.HealthCheckResponse_SERVING)
+	healthCtx, healthCancel := context.WithCancel(context.Background())
+	healthDone := make(chan struct{})
+	go func() {
+		defer close(healthDone)
+		watchDBHealth(healthCtx, repo, healthSrv, logger)
+	}()
+
 	go func() {
 		logger.Info("gRPC server listening", slog.String("addr", grpcPort))
 		if err := grpcSrv.Serve(lis); err != nil {
@@ -105,16 +451,74 @@ func main() {
 	}()
 
 	// ── REST API ──
-	handler := restapi.NewHandler(grpcImpl, repo, pool, uploadDir, db, logger)
+	collision, err := restapi.ParseCollisionStrategy(os.Getenv("COLLISION_STRATEGY"))
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	storageKey, err := restapi.ParseStorageKeyTemplate(os.Getenv("STORAGE_KEY_TEMPLATE"))
+	if err != nil {
+		logger.Error("invalid config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// The dashboard is embedded; STATIC_DIR overrides it with an on-disk copy.
+	staticDir := os.Getenv("STATIC_DIR")
+	var staticFS fs.FS = web.FS
+	if !feats["serve_static"] {
+		staticDir, staticFS = "", nil
+	}
+
+	handler := restapi.NewHandler(grpcImpl, repo, pool, uploadDir, logger, restapi.Config{
+		MaxConcurrentUploads: int64(envIntOrDefault("MAX_CONCURRENT_UPLOADS", 10)),
+		UploadSlotWait:       envDurationOrDefault("UPLOAD_SLOT_WAIT", 5*time.Second),
+		UploadTimeout:        envDurationOrDefault("UPLOAD_TIMEOUT", 10*time.Minute),
+		MultipartMaxMemory:   envInt64OrDefault("MULTIPART_MAX_MEMORY", 10<<20),
+		MaxFormFieldBytes:    envInt64OrDefault("MAX_FORM_FIELD_BYTES", 8<<10),
+		UploadField:          envOrDefault("UPLOAD_FIELD", "file"),
+		AnyUploadField:       feats["upload_any_field"],
+		DefaultQuotaBytes:    envInt64OrDefault("QUOTA_DEFAULT_BYTES", 0),
+		ClientQuotas:         parseQuotas(os.Getenv("CLIENT_QUOTAS")),
+		DefaultTTL:           envDurationOrDefault("DEFAULT_TTL", 0),
+		CollisionStrategy:    collision,
+		StorageKey:           storageKey,
+		DownloadMaxAge:       envDurationOrDefault("DOWNLOAD_MAX_AGE", 24*time.Hour),
+		StaticDir:            staticDir,
+		StaticFS:             staticFS,
+		AdminToken:           os.Getenv("ADMIN_TOKEN"),
+		JobQueue:             jobQueue,
+		OverflowPolicy:       overflow,
+		SpillQueue:           spillQueue,
+		IsLeader:             isLeader,
+		Events:               events,
+		FileLimiter:          fileLimiter,
+		MimeVolume:           mimeVolume,
+		DiskGuard:            diskGuard,
+		IDGenerator:          ids,
+		DBStats:              db.Stats,
+		Recovery:             recovery,
+		DedupWindow:          envDurationOrDefault("UPLOAD_DEDUP_WINDOW", 0),
+		MetadataLimits:       metaLimits,
+		RegisterRetry: restapi.RegisterRetry{
+			Attempts:         envIntOrDefault("REGISTER_ATTEMPTS", 1),
+			Backoff:          envDurationOrDefault("REGISTER_BACKOFF", 100*time.Millisecond),
+			BreakerThreshold: envIntOrDefault("REGISTER_BREAKER_THRESHOLD", 0),
+			BreakerCooldown:  envDurationOrDefault("REGISTER_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		Settings: runtimeSettings,
+	})
 	mux := http.NewServeMux()
 	handler.RegisterRoutes(mux)
 
+	// Server-wide timeouts apply to every route except POST /files, which
+	// sets its own deadline from UPLOAD_TIMEOUT.
 	httpSrv := &http.Server{
-		Addr:         httpPort,
-		Handler:      mux,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              httpPort,
+		Handler:           handler.AccessLog(mux),
+		ReadHeaderTimeout: envDurationOrDefault("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       envDurationOrDefault("HTTP_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:      envDurationOrDefault("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       envDurationOrDefault("HTTP_IDLE_TIMEOUT", 60*time.Second),
 	}
 
 	go func() {
@@ -130,66 +534,429 @@ func main() {
 	sig := <-sigCh
 	logger.Info("shutdown signal received", slog.String("signal", sig.String()))
 
-	// 1. Stop accepting new HTTP requests.
-	shutCtx, shutCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// 0. Report NOT_SERVING so load balancers drain traffic away.
+	healthCancel()
+	<-healthDone
+	healthSrv.Shutdown()
+
+	// Each stage below has its own budget; a stage that overruns is forced
+	// so one hung dependency cannot stall the whole shutdown.
+
+	// 1. Stop accepting new HTTP requests. Requests still running after
+	// HTTP_SHUTDOWN_TIMEOUT have their connections closed.
+	shutCtx, shutCancel := context.WithTimeout(context.Background(), envDurationOrDefault("HTTP_SHUTDOWN_TIMEOUT", 10*time.Second))
 	defer shutCancel()
 
 	if err := httpSrv.Shutdown(shutCtx); err != nil {
-		logger.Error("HTTP shutdown", slog.String("error", err.Error()))
+		logger.Warn("shutdown stage timed out, closing connections", slog.String("stage", "http"), slog.String("error", err.Error()))
+		httpSrv.Close()
 	}
 	logger.Info("HTTP server stopped")
 
-	// 2. Stop gRPC server gracefully.
-	grpcSrv.GracefulStop()
+	// 2. Stop gRPC server gracefully, or abort open RPCs once
+	// GRPC_SHUTDOWN_TIMEOUT has passed.
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-time.After(envDurationOrDefault("GRPC_SHUTDOWN_TIMEOUT", 10*time.Second)):
+		logger.Warn("shutdown stage timed out, stopping gRPC server", slog.String("stage", "grpc"))
+		grpcSrv.Stop()
+		<-grpcStopped
+	}
 	logger.Info("gRPC server stopped")
 
-	// 3. Drain worker pool.
-	pool.Shutdown()
-	logger.Info("worker pool drained")
+	// 3. Stop the retention janitor, artifact sweeper, settings refresh,
+	// disk check and crash recovery or queue feeder (they share a context).
+	// The feeder stops before the pool drains, so nothing is claimed that
+	// cannot be processed.
+	// These only have a context to cancel; if one ignores it past
+	// BACKGROUND_SHUTDOWN_TIMEOUT, shutdown carries on without it.
+	janitorCancel()
+	bgStopped := make(chan struct{})
+	go func() {
+		<-janitorDone
+		<-sweepDone
+		<-settingsDone
+		<-diskDone
+		<-leaderDone
+		<-recoveryDone
+		close(bgStopped)
+	}()
+	select {
+	case <-bgStopped:
+		logger.Info("background tasks stopped")
+	case <-time.After(envDurationOrDefault("BACKGROUND_SHUTDOWN_TIMEOUT", 10*time.Second)):
+		logger.Warn("shutdown stage timed out, abandoning background tasks", slog.String("stage", "background"))
+	}
+
+	// 4. Drain worker pool. HTTP shutdown has returned, so no new uploads can
+	// start; Shutdown then waits for any Submit still in flight before closing
+	// the jobs channel, and every accepted job is processed before Results closes.
+	// If draining outlives POOL_DRAIN_TIMEOUT the pool is cancelled instead;
+	// files it cuts short stay pending and are reprocessed after restart.
+	drained := make(chan struct{})
+	go func() {
+		pool.Shutdown()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		logger.Info("worker pool drained")
+	case <-time.After(envDurationOrDefault("POOL_DRAIN_TIMEOUT", 30*time.Second)):
+		logger.Warn("shutdown stage timed out, cancelling in-flight jobs", slog.String("stage", "pool"))
+		pool.Cancel()
+		<-drained
+		logger.Info("worker pool cancelled")
+	}
 
-	// 4. Wait for results handler to finish.
+	// 5. Wait for results handler to finish.
 	<-resultsDone
 	logger.Info("results handler finished")
 
 	logger.Info("GopherDrive shutdown complete")
 }
 
-// handleResults processes worker results and persists metadata back to the DB.
-func handleResults(results <-chan worker.Result, repo repository.Repository, logger *slog.Logger) {
-	for res := range results {
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+// resultHandler persists worker results back to the DB.
+type resultHandler struct {
+	repo           repository.Repository
+	queue          repository.JobQueue // nil unless jobs come from the jobs table
+	fixExt         bool                // correct extensions contradicting the detected MIME type
+	quarantineExec bool                // fail executable uploads instead of completing them
+	schema         *metaschema.Schema  // nil skips metadata validation
+	maxReason      int                 // byte cap on the stored failure reason
+	volume         *mimestats.Counter  // nil disables per-MIME volume counting
+	inlineItems    int                 // longer metadata lists move to file_metadata_items; 0 keeps all inline
+	itemSample     int                 // elements of a moved list kept inline as its sample
+	events         *eventlog.Log
+	logger         *slog.Logger
+}
 
-		if res.Err != nil {
-			logger.Error("processing failed for file",
+// run consumes results until the channel is closed. Results fed from the
+// durable queue are completed there once handled. Jobs abandoned by a
+// cancelled pool or a lost queue claim are neither: the record stays pending
+// and the queued job is re-claimed when its lease expires (or is already
+// held by another instance), so the file is processed again.
+func (rh *resultHandler) run(results <-chan worker.Result) {
+	for res := range results {
+		if res.Abandoned {
+			rh.logger.Warn("job abandoned, leaving file pending",
 				slog.String("file_id", res.FileID),
-				slog.String("error", res.Err.Error()),
+				slog.String("reason", res.Err.Error()),
+				slog.Int64("queue_id", res.QueueID),
 			)
-			if err := repo.UpdateStatus(ctx, res.FileID, "failed"); err != nil {
-				logger.Error("update status to failed", slog.String("error", err.Error()))
+			rh.events.Append(res.FileID, "abandoned", "left pending for reprocessing")
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		rh.handle(ctx, res)
+		if rh.queue != nil && res.QueueID != 0 {
+			if err := rh.queue.Complete(ctx, res.QueueID); err != nil {
+				// The job is re-claimed after its lease expires and reprocessed.
+				rh.logger.Error("complete queued job", slog.Int64("queue_id", res.QueueID), slog.String("error", err.Error()))
 			}
-			cancel()
+		}
+		cancel()
+	}
+}
+
+// mergeClientMetadata adds the record's stored non-reserved metadata keys,
+// i.e. those the client supplied at upload, to res.Metadata. Reserved keys
+// left from an earlier run are dropped so the new result replaces them.
+func (rh *resultHandler) mergeClientMetadata(ctx context.Context, res *worker.Result) error {
+	rec, err := rh.repo.GetByID(ctx, res.FileID)
+	if err != nil {
+		return err
+	}
+	for k, v := range rec.Metadata {
+		if metaschema.ReservedKey(k) {
 			continue
 		}
+		if _, ok := res.Metadata[k]; !ok {
+			if res.Metadata == nil {
+				res.Metadata = map[string]interface{}{}
+			}
+			res.Metadata[k] = v
+		}
+	}
+	return nil
+}
+
+// discardStale reports whether err means res belongs to a superseded
+// processing epoch, logging the discard if so. The newer job owns the record.
+func (rh *resultHandler) discardStale(res worker.Result, err error) bool {
+	if !errors.Is(err, repository.ErrStaleEpoch) {
+		return false
+	}
+	rh.logger.Warn("discarding stale result",
+		slog.Int("worker_id", res.WorkerID),
+		slog.String("file_id", res.FileID),
+		slog.Int64("epoch", res.Epoch),
+	)
+	rh.events.Append(res.FileID, "stale_result_discarded", "")
+	return true
+}
+
+// rejectByPolicy reports whether err is a content-policy rejection and, if
+// so, fails the file with that reason so it does not stay processing.
+func (rh *resultHandler) rejectByPolicy(ctx context.Context, res worker.Result, err error) bool {
+	if !errors.Is(err, repository.ErrPolicyViolation) {
+		return false
+	}
+	res.Err = err
+	rh.handle(ctx, res)
+	return true
+}
+
+// handle persists a single worker result.
+func (rh *resultHandler) handle(ctx context.Context, res worker.Result) {
+	repo, logger := rh.repo, rh.logger
+
+	if res.Err != nil {
+		logger.Error("processing failed for file",
+			slog.Int("worker_id", res.WorkerID),
+			slog.String("file_id", res.FileID),
+			slog.String("error", res.Err.Error()),
+		)
+		reason := repository.TruncateReason(res.Err.Error(), rh.maxReason)
+		if err := repo.MarkFailed(ctx, res.FileID, res.Epoch, reason); err != nil {
+			if rh.discardStale(res, err) {
+				return
+			}
+			logger.Error("update status to failed", slog.String("error", err.Error()))
+			rh.events.Append(res.FileID, "store_failed", "update status: "+err.Error())
+			return
+		}
+		rh.events.Append(res.FileID, "status_failed", res.Err.Error())
+		rh.volume.Observe("", repository.StatusFailed, 0)
+		return
+	}
+
+	// Read before sanitizing, which may drop keys the schema does not know.
+	execFormat, _ := res.Metadata["executable_format"].(string)
+	mimeType, _ := res.Metadata["mime_type"].(string)
+
+	// Schema violations never fail the job: offending keys are dropped and
+	// the rest is stored.
+	if meta, violations := rh.schema.Sanitize(res.Metadata); len(violations) > 0 {
+		logger.Warn("metadata violates schema, storing sanitized copy",
+			slog.String("file_id", res.FileID),
+			slog.Any("violations", violations),
+		)
+		rh.events.Append(res.FileID, "metadata_sanitized", strings.Join(violations, "; "))
+		res.Metadata = meta
+	}
+
+	// Metadata the client sent with the upload sits on the pending record;
+	// carry it over so completion does not overwrite it. Computed keys win.
+	if err := rh.mergeClientMetadata(ctx, &res); err != nil {
+		logger.Error("load client metadata", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
+		rh.events.Append(res.FileID, "store_failed", "load client metadata: "+err.Error())
+		return
+	}
+
+	// Long lists (archive entries and the like) go to the items table; the
+	// record keeps a count and a sample. If that write fails the lists stay
+	// inline rather than being lost.
+	if rh.inlineItems > 0 {
+		if inline, spilled := repository.SplitListMetadata(res.Metadata, rh.inlineItems, rh.itemSample); spilled != nil {
+			if err := repo.ReplaceMetadataItems(ctx, res.FileID, spilled); err != nil {
+				logger.Warn("store metadata items, keeping lists inline", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
+			} else {
+				res.Metadata = inline
+			}
+		}
+	}
+
+	// Extensionless uploads are told the extension their type calls for;
+	// downloads append it to the name and FIX_EXTENSIONS to the stored path.
+	if res.Metadata != nil && filepath.Ext(res.FilePath) == "" {
+		if ext := inferredExt(mimeType); ext != "" {
+			res.Metadata["inferred_extension"] = ext
+		}
+	}
+
+	// Quarantined executables keep their metadata, so clients can see why,
+	// but are failed instead of completed and therefore never served.
+	if rh.quarantineExec && execFormat != "" {
+		reason := "quarantined: executable content (" + execFormat + ")"
+		err := repo.WithTx(ctx, func(tx repository.RepositoryTx) error {
+			if err := tx.UpdateMetadata(ctx, res.FileID, res.Hash, res.Size, res.Metadata); err != nil {
+				return err
+			}
+			return tx.MarkFailed(ctx, res.FileID, res.Epoch, reason)
+		})
+		if rh.discardStale(res, err) || rh.rejectByPolicy(ctx, res, err) {
+			return
+		}
+		if err != nil {
+			logger.Error("quarantine file", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
+			rh.events.Append(res.FileID, "store_failed", "quarantine: "+err.Error())
+			return
+		}
+		logger.Warn("executable upload quarantined", slog.String("file_id", res.FileID), slog.String("format", execFormat))
+		rh.events.Append(res.FileID, "quarantined", execFormat)
+		rh.volume.Observe(mimeType, repository.StatusFailed, res.Size)
+		return
+	}
+
+	// Store hash + size + metadata and mark completed in one write,
+	// correcting the extension first if enabled.
+	stored := false
+	if rh.fixExt {
+		newExt, err := storeWithCorrectedExt(ctx, repo, res)
+		switch {
+		case rh.discardStale(res, err):
+			return
+		case err != nil:
+			logger.Warn("extension correction failed, keeping original name", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
+			rh.events.Append(res.FileID, "extension_correction_failed", err.Error())
+		case newExt != "":
+			logger.Info("file extension corrected", slog.String("file_id", res.FileID), slog.String("to", newExt))
+			rh.events.Append(res.FileID, "extension_corrected", newExt)
+			stored = true
+		}
+	}
+	if !stored {
+		if err := repo.CompleteProcessing(ctx, res.FileID, res.Epoch, res.Hash, res.Size, res.Metadata); err != nil {
+			if rh.discardStale(res, err) || rh.rejectByPolicy(ctx, res, err) {
+				return
+			}
+			logger.Error("complete processing", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
+			rh.events.Append(res.FileID, "store_failed", "complete processing: "+err.Error())
+			return
+		}
+	}
+	rh.events.Append(res.FileID, "status_completed", "")
+	rh.volume.Observe(mimeType, repository.StatusCompleted, res.Size)
+	hashAttr := slog.String("hash", res.Hash)
+	if short, _ := res.Metadata["short_hash"].(string); short != "" {
+		hashAttr = slog.String("short_hash", short)
+	}
+	logger.Info("file processing completed",
+		slog.Int("worker_id", res.WorkerID),
+		slog.String("file_id", res.FileID),
+		hashAttr,
+		slog.Int64("size", res.Size),
+	)
+}
+
+// runJanitor deletes expired files every interval until ctx is cancelled.
+// Ticks are skipped while isLeader reports false. In dry-run mode each tick
+// only logs the files it would have removed.
+func runJanitor(ctx context.Context, repo repository.Repository, interval time.Duration, dryRun bool, isLeader func() bool, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-		// Update hash + size + metadata.
-		if err := repo.UpdateMetadata(ctx, res.FileID, res.Hash, res.Size, res.Metadata); err != nil {
-			logger.Error("update metadata", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
-			cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !isLeader() {
 			continue
 		}
 
-		// Mark as completed.
-		if err := repo.UpdateStatus(ctx, res.FileID, "completed"); err != nil {
-			logger.Error("update status to completed", slog.String("file_id", res.FileID), slog.String("error", err.Error()))
-		} else {
-			logger.Info("file processing completed",
-				slog.String("file_id", res.FileID),
-				slog.String("hash", res.Hash),
-				slog.Int64("size", res.Size),
-			)
+		expired, err := repo.ListExpired(ctx, time.Now(), janitorBatch)
+		if err != nil {
+			logger.Error("janitor list expired", slog.String("error", err.Error()))
+			continue
 		}
-		cancel()
+		if dryRun {
+			if len(expired) > 0 {
+				ids := make([]string, len(expired))
+				for i, rec := range expired {
+					ids[i] = rec.ID
+				}
+				logger.Info("janitor dry run: expired files not removed", slog.Int("would_remove", len(ids)), slog.Any("ids", ids))
+			}
+			continue
+		}
+
+		for _, rec := range expired {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := os.Remove(rec.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Error("janitor remove file", slog.String("file_id", rec.ID), slog.String("error", err.Error()))
+				continue
+			}
+			if err := repo.Delete(ctx, rec.ID); err != nil {
+				logger.Error("janitor delete record", slog.String("file_id", rec.ID), slog.String("error", err.Error()))
+				continue
+			}
+			logger.Info("expired file removed", slog.String("file_id", rec.ID), slog.Time("expires_at", rec.ExpiresAt))
+		}
+	}
+}
+
+// watchDBHealth pings the database periodically and flips the gRPC health
+// status between SERVING and NOT_SERVING. It returns when ctx is cancelled.
+func watchDBHealth(ctx context.Context, repo repository.Repository, healthSrv *health.Server, logger *slog.Logger) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	serving := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := repo.Ping(ctx)
+
+		switch {
+		case err != nil && serving:
+			logger.Warn("database ping failed, gRPC health NOT_SERVING", slog.String("error", err.Error()))
+			setServingStatus(healthSrv, healthpb.HealthCheckResponse_NOT_SERVING)
+			serving = false
+		case err == nil && !serving:
+			logger.Info("database reachable again, gRPC health SERVING")
+			setServingStatus(healthSrv, healthpb.HealthCheckResponse_SERVING)
+			serving = true
+		}
+	}
+}
+
+// setServingStatus updates both the overall ("") and the MetadataService health entries.
+func setServingStatus(healthSrv *health.Server, st healthpb.HealthCheckResponse_ServingStatus) {
+	healthSrv.SetServingStatus("", st)
+	healthSrv.SetServingStatus(pb.ServiceDesc.ServiceName, st)
+}
+
+// dbPoolConfig holds database/sql connection pool settings. Zero values keep
+// the database/sql meaning: no limit on open connections, lifetime or idle time.
+type dbPoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// validate rejects settings database/sql would silently adjust or misread.
+func (c dbPoolConfig) validate() error {
+	switch {
+	case c.MaxOpenConns < 0 || c.MaxIdleConns < 0:
+		return fmt.Errorf("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	case c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns:
+		return fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	case c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0:
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME must not be negative")
 	}
+	return nil
+}
+
+// apply configures db with the pool settings.
+func (c dbPoolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
 }
 
 // envOrDefault reads an env variable or returns the fallback.
@@ -200,6 +967,93 @@ func envOrDefault(key, fallback string) string {
 	return fallback
 }
 
+// envIntOrDefault reads an integer env variable or returns the fallback.
+func envIntOrDefault(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Warn("invalid integer env, using default", slog.String("key", key), slog.String("value", v))
+		return fallback
+	}
+	return n
+}
+
+// envInt64OrDefault reads an int64 env variable or returns the fallback.
+func envInt64OrDefault(key string, fallback int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		slog.Warn("invalid integer env, using default", slog.String("key", key), slog.String("value", v))
+		return fallback
+	}
+	return n
+}
+
+// parseQuotas parses per-client quotas in the form "alice=1048576,bob=0".
+// Malformed entries are skipped with a warning.
+func parseQuotas(v string) map[string]int64 {
+	quotas := make(map[string]int64)
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		client, limit, ok := strings.Cut(entry, "=")
+		n, err := strconv.ParseInt(strings.TrimSpace(limit), 10, 64)
+		if !ok || err != nil || strings.TrimSpace(client) == "" {
+			slog.Warn("invalid CLIENT_QUOTAS entry, skipping", slog.String("entry", entry))
+			continue
+		}
+		quotas[strings.TrimSpace(client)] = n
+	}
+	return quotas
+}
+
+// envBoolOrDefault reads a boolean env variable (e.g. "true", "0") or returns the fallback.
+func envBoolOrDefault(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		slog.Warn("invalid boolean env, using default", slog.String("key", key), slog.String("value", v))
+		return fallback
+	}
+	return b
+}
+
+// envDurationOrDefault reads a time.Duration env variable (e.g. "5s") or returns the fallback.
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("invalid duration env, using default", slog.String("key", key), slog.String("value", v))
+		return fallback
+	}
+	return d
+}
+
+// envIntervalOrDefault is envDurationOrDefault for tick intervals, which must
+// be positive: zero or a negative value falls back too.
+func envIntervalOrDefault(key string, fallback time.Duration) time.Duration {
+	d := envDurationOrDefault(key, fallback)
+	if d <= 0 {
+		slog.Warn("non-positive interval env, using default", slog.String("key", key), slog.Duration("value", d))
+		return fallback
+	}
+	return d
+}
+
 func init() {
 	// Suppress unused import warning for fmt.
 	_ = fmt.Sprintf
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+	"github.com/mtiwari1/gopherdrive/internal/worker"
+)
+
+// recoveryBatch is how many pending records are loaded per query, and how
+// many are resubmitted between progress log lines.
+const recoveryBatch = 100
+
+// recoveryPoll is how often throttled recovery rechecks the queue depth.
+const recoveryPoll = 100 * time.Millisecond
+
+// listPending loads every record left pending by a previous process, which
+// lost its in-memory queue when it died. It must run before the REST API
+// starts accepting uploads, so that new pending files are never queued twice.
+func listPending(ctx context.Context, repo repository.Repository) ([]*repository.FileRecord, error) {
+	var (
+		pending []*repository.FileRecord
+		afterID string
+	)
+	for {
+		recs, err := repo.ListByStatus(ctx, repository.StatusPending, afterID, recoveryBatch)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, recs...)
+		if len(recs) < recoveryBatch {
+			return pending, nil
+		}
+		afterID = recs[len(recs)-1].ID
+	}
+}
+
+// resubmitPending queues the recovered records. Those whose file is gone from
+// disk are marked failed instead. Per-upload extractor options are not
+// persisted, so recovered jobs run with the defaults.
+//
+// A large backlog is fed in gradually: each job waits until fewer than
+// maxQueued jobs are queued, leaving the rest of the queue to live uploads.
+// maxQueued <= 0 submits as fast as the pool accepts.
+func resubmitPending(ctx context.Context, repo repository.Repository, pool *worker.Pool, pending []*repository.FileRecord, maxQueued int, progress *worker.RecoveryProgress, logger *slog.Logger) {
+	progress.SetTotal(len(pending))
+	logger.Info("pending file recovery started",
+		slog.Int("pending", len(pending)),
+		slog.Int("max_queued", maxQueued),
+	)
+	defer func() {
+		progress.Finish()
+		s := progress.Snapshot()
+		logger.Info("pending file recovery finished",
+			slog.Int64("resubmitted", s.Resubmitted),
+			slog.Int64("missing", s.Missing),
+			slog.Int64("errors", s.Errors),
+			slog.Int64("remaining", s.Remaining),
+		)
+	}()
+
+	for i, rec := range pending {
+		if i > 0 && i%recoveryBatch == 0 {
+			s := progress.Snapshot()
+			logger.Info("pending file recovery progress",
+				slog.Int64("resubmitted", s.Resubmitted),
+				slog.Int64("remaining", s.Remaining),
+				slog.Int("queue_depth", pool.QueueDepth()),
+			)
+		}
+		if !pool.WaitQueueBelow(ctx, maxQueued, recoveryPoll) {
+			return
+		}
+
+		if _, err := os.Stat(rec.FilePath); errors.Is(err, os.ErrNotExist) {
+			logger.Warn("recovery: file missing on disk, marking failed", slog.String("file_id", rec.ID), slog.String("path", rec.FilePath))
+			if err := repo.MarkFailed(ctx, rec.ID, rec.Epoch, "file missing on disk after restart"); err != nil {
+				logger.Error("recovery mark failed", slog.String("file_id", rec.ID), slog.String("error", err.Error()))
+			}
+			progress.Missing()
+			continue
+		}
+
+		// A new epoch turns any result still owed by the previous process,
+		// or by another instance, into a stale one.
+		epoch, err := repo.BeginProcessing(ctx, rec.ID)
+		if err != nil {
+			logger.Error("recovery begin processing", slog.String("file_id", rec.ID), slog.String("error", err.Error()))
+			progress.Error()
+			continue
+		}
+
+		// Submit can still block if uploads filled the queue meanwhile;
+		// recovery runs in the background so it never holds up startup.
+		if !pool.Submit(worker.Job{Ctx: context.Background(), FileID: rec.ID, FilePath: rec.FilePath, Epoch: epoch}) {
+			return // shutting down
+		}
+		progress.Resubmitted()
+	}
+}
+
+// requeuer returns the grpcserver.Config.Requeue hook: a file moved back to
+// pending is enqueued on the durable queue when there is one, whose Claim
+// bumps the epoch again, or submitted to the pool at the epoch the status
+// change set.
+func requeuer(queue repository.JobQueue, pool *worker.Pool) func(context.Context, *repository.FileRecord, int64) error {
+	return func(ctx context.Context, rec *repository.FileRecord, epoch int64) error {
+		if queue != nil {
+			return queue.Enqueue(ctx, &repository.QueuedJob{FileID: rec.ID, FilePath: rec.FilePath})
+		}
+		if !pool.Submit(worker.Job{Ctx: context.Background(), FileID: rec.ID, FilePath: rec.FilePath, Epoch: epoch}) {
+			return errors.New("worker pool closed")
+		}
+		return nil
+	}
+}
@@ -5,6 +5,8 @@ go 1.22
 require (
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/google/uuid v1.6.0
+	go.uber.org/goleak v1.3.0
+	golang.org/x/sync v0.6.0
 	google.golang.org/grpc v1.62.1
 )
 
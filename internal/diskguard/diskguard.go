@@ -0,0 +1,120 @@
+// Package diskguard watches space on the upload volume so uploads can be
+// refused before the disk fills, while reads and queued processing go on.
+package diskguard
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Stats is the latest usage sample.
+type Stats struct {
+	UsedPercent float64   `json:"used_percent"`
+	TotalBytes  uint64    `json:"total_bytes"`
+	FreeBytes   uint64    `json:"free_bytes"` // available to unprivileged users
+	Throttled   bool      `json:"throttled"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Guard throttles uploads once usage reaches the high-water mark and lifts
+// the throttle when it falls below the low-water mark; the gap keeps it
+// from flapping around a single threshold. A nil *Guard never throttles.
+type Guard struct {
+	dir       string
+	high, low float64
+	logger    *slog.Logger
+
+	throttled atomic.Bool
+	stats     atomic.Pointer[Stats]
+}
+
+// New creates a Guard for the volume holding dir with watermarks in percent
+// used. Non-positive high returns nil (disabled).
+func New(dir string, high, low float64, logger *slog.Logger) (*Guard, error) {
+	if high <= 0 {
+		return nil, nil
+	}
+	if high > 100 || low < 0 || low >= high {
+		return nil, fmt.Errorf("diskguard: need 0 <= low < high <= 100, got low=%g high=%g", low, high)
+	}
+	return &Guard{dir: dir, high: high, low: low, logger: logger}, nil
+}
+
+// Check samples usage once and updates the throttle.
+func (g *Guard) Check() error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(g.dir, &st); err != nil {
+		return fmt.Errorf("diskguard: statfs %s: %w", g.dir, err)
+	}
+	bsize := uint64(st.Bsize)
+	used := (st.Blocks - st.Bfree) * bsize
+	avail := st.Bavail * bsize
+	// Like df, measure against the space usable by unprivileged writers.
+	var pct float64
+	if used+avail > 0 {
+		pct = float64(used) / float64(used+avail) * 100
+	}
+
+	throttled := g.throttled.Load()
+	switch {
+	case !throttled && pct >= g.high:
+		throttled = true
+		g.logger.Warn("disk above high-water mark, throttling uploads", slog.Float64("used_percent", pct), slog.Float64("high", g.high))
+	case throttled && pct < g.low:
+		throttled = false
+		g.logger.Info("disk below low-water mark, accepting uploads", slog.Float64("used_percent", pct), slog.Float64("low", g.low))
+	}
+	g.throttled.Store(throttled)
+	g.stats.Store(&Stats{
+		UsedPercent: pct,
+		TotalBytes:  st.Blocks * bsize,
+		FreeBytes:   avail,
+		Throttled:   throttled,
+		CheckedAt:   time.Now(),
+	})
+	return nil
+}
+
+// defaultInterval is used by Run when interval is not positive.
+const defaultInterval = 30 * time.Second
+
+// Run checks usage every interval until ctx is cancelled. A zero or negative
+// interval selects 30s.
+func (g *Guard) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := g.Check(); err != nil {
+			g.logger.Error("disk usage check", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// Throttled reports whether new uploads should be refused.
+func (g *Guard) Throttled() bool {
+	return g != nil && g.throttled.Load()
+}
+
+// Stats returns the latest sample; ok is false before the first check.
+func (g *Guard) Stats() (s Stats, ok bool) {
+	if g == nil {
+		return Stats{}, false
+	}
+	if p := g.stats.Load(); p != nil {
+		return *p, true
+	}
+	return Stats{}, false
+}
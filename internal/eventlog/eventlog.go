@@ -0,0 +1,130 @@
+// Package eventlog keeps a short, in-memory history of processing events per
+// file so operators can see what happened to a file without grepping logs.
+package eventlog
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is one step in a file's processing history.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"event"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Update is an event as delivered to subscribers, tagged with its file.
+type Update struct {
+	FileID string
+	Event
+}
+
+// subscriberBuffer is how many undelivered updates a subscriber may fall
+// behind before further ones are dropped for it.
+const subscriberBuffer = 64
+
+type subscriber struct {
+	fileID string // "" receives every file
+	ch     chan Update
+}
+
+// Log retains up to perFile events for each of the maxFiles most recently
+// created files. A nil *Log discards events, so callers need no nil checks.
+// History is per process: it is lost on restart and not shared by replicas.
+type Log struct {
+	perFile  int
+	maxFiles int
+
+	mu    sync.Mutex
+	files map[string][]Event
+	order []string // file IDs, oldest first, for eviction
+	subs  map[*subscriber]struct{}
+}
+
+// New creates a Log. Non-positive limits select 50 events per file and
+// 10000 files.
+func New(perFile, maxFiles int) *Log {
+	if perFile <= 0 {
+		perFile = 50
+	}
+	if maxFiles <= 0 {
+		maxFiles = 10000
+	}
+	return &Log{
+		perFile:  perFile,
+		maxFiles: maxFiles,
+		files:    make(map[string][]Event),
+		subs:     make(map[*subscriber]struct{}),
+	}
+}
+
+// Append records an event for fileID, dropping the file's oldest event once
+// perFile is reached and the least recently created file once maxFiles is.
+func (l *Log) Append(fileID, kind, detail string) {
+	if l == nil || fileID == "" {
+		return
+	}
+	ev := Event{Time: time.Now(), Kind: kind, Detail: detail}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events, ok := l.files[fileID]
+	if !ok {
+		if len(l.order) >= l.maxFiles {
+			delete(l.files, l.order[0])
+			l.order = l.order[1:]
+		}
+		l.order = append(l.order, fileID)
+	}
+	if len(events) >= l.perFile {
+		events = append(events[:0], events[1:]...)
+	}
+	l.files[fileID] = append(events, ev)
+
+	for s := range l.subs {
+		if s.fileID != "" && s.fileID != fileID {
+			continue
+		}
+		select {
+		case s.ch <- Update{FileID: fileID, Event: ev}:
+		default: // never let a slow subscriber stall processing
+		}
+	}
+}
+
+// Subscribe delivers events appended from now on for fileID, or for every
+// file when fileID is "". Updates are dropped, not queued, once the
+// subscriber is subscriberBuffer behind. Call cancel to stop delivery; it
+// closes the channel. On a nil *Log the channel never delivers.
+func (l *Log) Subscribe(fileID string) (updates <-chan Update, cancel func()) {
+	if l == nil {
+		return nil, func() {}
+	}
+	s := &subscriber{fileID: fileID, ch: make(chan Update, subscriberBuffer)}
+
+	l.mu.Lock()
+	l.subs[s] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subs, s)
+			l.mu.Unlock()
+			close(s.ch)
+		})
+	}
+}
+
+// Events returns a copy of the retained events for fileID, oldest first.
+func (l *Log) Events(fileID string) []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event(nil), l.files[fileID]...)
+}
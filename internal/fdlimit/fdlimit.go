@@ -0,0 +1,67 @@
+// Package fdlimit bounds how many file handles the server's I/O paths hold
+// open at once, so bursts queue instead of failing with EMFILE.
+package fdlimit
+
+import (
+	"context"
+	"sync/atomic"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// Stats reports descriptor pressure.
+type Stats struct {
+	Max     int64 `json:"max"`
+	InUse   int64 `json:"in_use"`
+	Waiting int64 `json:"waiting"`
+}
+
+// Limiter hands out file-handle slots. A nil *Limiter never blocks, so
+// callers need no nil checks when the limit is disabled.
+type Limiter struct {
+	sem     *semaphore.Weighted
+	max     int64
+	inUse   atomic.Int64
+	waiting atomic.Int64
+}
+
+// New creates a Limiter allowing max concurrent slots. Non-positive max
+// returns nil (unlimited).
+func New(max int64) *Limiter {
+	if max <= 0 {
+		return nil
+	}
+	return &Limiter{sem: semaphore.NewWeighted(max), max: max}
+}
+
+// Acquire takes n slots, waiting until they are free or ctx is done.
+func (l *Limiter) Acquire(ctx context.Context, n int64) error {
+	if l == nil {
+		return nil
+	}
+	l.waiting.Add(1)
+	err := l.sem.Acquire(ctx, n)
+	l.waiting.Add(-1)
+	if err != nil {
+		return err
+	}
+	l.inUse.Add(n)
+	return nil
+}
+
+// Release returns n slots taken by Acquire.
+func (l *Limiter) Release(n int64) {
+	if l == nil {
+		return
+	}
+	l.inUse.Add(-n)
+	l.sem.Release(n)
+}
+
+// Stats returns the current usage. A nil Limiter reports all zeros.
+func (l *Limiter) Stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+	return Stats{Max: l.max, InUse: l.inUse.Load(), Waiting: l.waiting.Load()}
+}
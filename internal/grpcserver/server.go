@@ -4,27 +4,48 @@ package grpcserver
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/mtiwari1/gopherdrive/internal/eventlog"
+	"github.com/mtiwari1/gopherdrive/internal/metaschema"
 	"github.com/mtiwari1/gopherdrive/internal/repository"
 	pb "github.com/mtiwari1/gopherdrive/proto"
 
+	"github.com/go-sql-driver/mysql"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// Config holds tunables for the gRPC service. The zero value applies no limits.
+type Config struct {
+	// MetadataLimits bounds client-supplied metadata; requests over the
+	// limits are rejected with InvalidArgument.
+	MetadataLimits metaschema.Limits
+
+	// Events records status changes made through UpdateStatus and feeds
+	// WatchStatus. Nil disables both; WatchStatus then returns Unimplemented.
+	Events *eventlog.Log
+
+	// Requeue submits a file that UpdateStatus moved back to pending for
+	// processing; epoch is the record's new epoch. Nil leaves the file for
+	// the next startup's recovery.
+	Requeue func(ctx context.Context, rec *repository.FileRecord, epoch int64) error
+}
+
 // Server implements the GopherDriveServer gRPC interface.
 // Dependencies are injected via the constructor — no global state.
 type Server struct {
 	repo   repository.Repository
 	logger *slog.Logger
+	cfg    Config
 }
 
 // NewServer creates a gRPC server with the given repository (DI).
-func NewServer(repo repository.Repository, logger *slog.Logger) *Server {
-	return &Server{repo: repo, logger: logger}
+func NewServer(repo repository.Repository, logger *slog.Logger, cfg Config) *Server {
+	return &Server{repo: repo, logger: logger, cfg: cfg}
 }
 
 // RegisterFile creates a new file record in the database.
@@ -32,18 +53,43 @@ func (s *Server) RegisterFile(ctx context.Context, req *pb.RegisterFileRequest)
 	s.logger.Info("grpc RegisterFile",
 		slog.String("file_id", req.Id),
 		slog.String("file_path", req.FilePath),
+		slog.String("owner", req.Owner),
 	)
 
 	rec := &repository.FileRecord{
-		ID:       req.Id,
-		Hash:     "",
-		Size:     0,
-		Status:   req.Status,
-		FilePath: req.FilePath,
+		ID:           req.Id,
+		Hash:         "",
+		Size:         req.Size,
+		Status:       req.Status,
+		FilePath:     req.FilePath,
+		Owner:        req.Owner,
+		OriginalName: req.OriginalName,
+	}
+	if rec.Owner == "" {
+		rec.Owner = repository.DefaultOwner
+	}
+	if req.ExpiresAt > 0 {
+		rec.ExpiresAt = time.Unix(req.ExpiresAt, 0).UTC()
 	}
 
-	if err := s.repo.Create(ctx, rec); err != nil {
-		return nil, mapDBError(err, "RegisterFile")
+	// Client metadata is stored on the pending record; processing merges
+	// the computed keys over it.
+	create := s.repo.Create
+	if req.MetadataJson != "" {
+		if err := json.Unmarshal([]byte(req.MetadataJson), &rec.Metadata); err != nil || rec.Metadata == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "RegisterFile: metadata_json must be a JSON object")
+		}
+		if err := s.cfg.MetadataLimits.Check(rec.Metadata); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "RegisterFile: %v", err)
+		}
+		if err := metaschema.CheckClientKeys(rec.Metadata); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "RegisterFile: %v", err)
+		}
+		create = s.repo.CreateWithMetadata
+	}
+
+	if err := create(ctx, rec); err != nil {
+		return nil, mapDBError(err, "RegisterFile", req.Id)
 	}
 
 	return &pb.RegisterFileResponse{
@@ -52,15 +98,98 @@ func (s *Server) RegisterFile(ctx context.Context, req *pb.RegisterFileRequest)
 	}, nil
 }
 
-// UpdateStatus changes the processing status of a file.
+// RegisterFileWithMetadata imports an externally hashed file as a completed
+// record in one transaction, bypassing the worker pool. With Upsert set, an
+// existing record is overwritten instead of returning AlreadyExists.
+func (s *Server) RegisterFileWithMetadata(ctx context.Context, req *pb.RegisterFileWithMetadataRequest) (*pb.RegisterFileResponse, error) {
+	s.logger.Info("grpc RegisterFileWithMetadata",
+		slog.String("file_id", req.Id),
+		slog.String("file_path", req.FilePath),
+		slog.String("hash", req.Hash),
+		slog.Bool("upsert", req.Upsert),
+	)
+
+	if req.Id == "" || req.FilePath == "" || req.Hash == "" || req.Size < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "RegisterFileWithMetadata: id, file_path, hash and a non-negative size are required")
+	}
+
+	meta := make(map[string]interface{}, len(req.Metadata))
+	for k, v := range req.Metadata {
+		meta[k] = v
+	}
+	if err := s.cfg.MetadataLimits.Check(meta); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "RegisterFileWithMetadata: %v", err)
+	}
+
+	rec := &repository.FileRecord{
+		ID:       req.Id,
+		Hash:     req.Hash,
+		Size:     req.Size,
+		Status:   repository.StatusCompleted,
+		FilePath: req.FilePath,
+		Metadata: meta,
+		Owner:    req.Owner,
+	}
+	if rec.Owner == "" {
+		rec.Owner = repository.DefaultOwner
+	}
+
+	var err error
+	if req.Upsert {
+		err = s.repo.Upsert(ctx, rec)
+	} else {
+		err = s.repo.CreateWithMetadata(ctx, rec)
+	}
+	if err != nil {
+		return nil, mapDBError(err, "RegisterFileWithMetadata", req.Id)
+	}
+
+	return &pb.RegisterFileResponse{
+		Id:     rec.ID,
+		Status: rec.Status,
+	}, nil
+}
+
+// UpdateStatus changes the processing status of a file. The change must be a
+// legal transition of the status state machine (see repository.CanTransition)
+// and applies only if the status is still the one read. It bumps the epoch,
+// so cancelling a file also discards the result of processing under way; a
+// requeued file is handed to Config.Requeue.
 func (s *Server) UpdateStatus(ctx context.Context, req *pb.UpdateStatusRequest) (*pb.UpdateStatusResponse, error) {
 	s.logger.Info("grpc UpdateStatus",
 		slog.String("file_id", req.Id),
 		slog.String("new_status", req.Status),
 	)
 
-	if err := s.repo.UpdateStatus(ctx, req.Id, req.Status); err != nil {
-		return nil, mapDBError(err, "UpdateStatus")
+	if !repository.IsValidStatus(req.Status) {
+		return nil, status.Errorf(codes.InvalidArgument, "UpdateStatus: unknown status %q", req.Status)
+	}
+	rec, err := s.repo.GetByID(ctx, req.Id)
+	if err != nil {
+		return nil, mapDBError(err, "UpdateStatus", req.Id)
+	}
+	if !repository.CanTransition(rec.Status, req.Status) {
+		return nil, status.Errorf(codes.FailedPrecondition, "UpdateStatus: cannot change status from %s to %s", rec.Status, req.Status)
+	}
+
+	if req.Status == rec.Status {
+		return &pb.UpdateStatusResponse{Id: req.Id, Status: req.Status}, nil
+	}
+
+	epoch, err := s.repo.TransitionStatus(ctx, req.Id, rec.Status, req.Status)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return nil, status.Errorf(codes.FailedPrecondition, "UpdateStatus: status of %q changed concurrently", req.Id)
+	}
+	if err != nil {
+		return nil, mapDBError(err, "UpdateStatus", req.Id)
+	}
+	s.cfg.Events.Append(req.Id, "status_"+req.Status, "from "+rec.Status)
+
+	if req.Status == repository.StatusPending && s.cfg.Requeue != nil {
+		if err := s.cfg.Requeue(ctx, rec, epoch); err != nil {
+			// The record is pending, so startup recovery still picks it up.
+			s.logger.Error("requeue file", slog.String("file_id", req.Id), slog.String("error", err.Error()))
+		}
 	}
 
 	return &pb.UpdateStatusResponse{
@@ -69,14 +198,21 @@ func (s *Server) UpdateStatus(ctx context.Context, req *pb.UpdateStatusRequest)
 	}, nil
 }
 
-// mapDBError converts database errors to proper gRPC status codes.
-func mapDBError(err error, method string) error {
+// mysqlErrDupEntry is MySQL's ER_DUP_ENTRY: a primary or unique key
+// already exists.
+const mysqlErrDupEntry = 1062
+
+// mapDBError converts database errors to proper gRPC status codes. id names
+// the file in NotFound and AlreadyExists messages.
+func mapDBError(err error, method, id string) error {
 	if errors.Is(err, sql.ErrNoRows) {
-		return status.Errorf(codes.NotFound, "%s: file not found", method)
+		return status.Errorf(codes.NotFound, "%s: file %q not found", method, id)
+	}
+	if errors.Is(err, repository.ErrPolicyViolation) {
+		return status.Errorf(codes.InvalidArgument, "%s: %v", method, err)
 	}
-	// MySQL duplicate‐entry errors contain "Duplicate entry" in the message.
 	if isDuplicateEntry(err) {
-		return status.Errorf(codes.AlreadyExists, "%s: file already exists", method)
+		return status.Errorf(codes.AlreadyExists, "%s: file %q already exists", method, id)
 	}
 	if errors.Is(err, context.DeadlineExceeded) {
 		return status.Errorf(codes.DeadlineExceeded, "%s: database timeout", method)
@@ -84,25 +220,8 @@ func mapDBError(err error, method string) error {
 	return status.Errorf(codes.Internal, "%s: %v", method, err)
 }
 
-// isDuplicateEntry checks for MySQL duplicate-key errors (error number 1062).
+// isDuplicateEntry reports whether err wraps a MySQL duplicate-key error.
 func isDuplicateEntry(err error) bool {
-	return err != nil && fmt.Sprintf("%v", err) != "" &&
-		(errors.As(err, new(interface{ Number() uint16 })) ||
-			containsDuplicate(err))
-}
-
-func containsDuplicate(err error) bool {
-	return err != nil && len(err.Error()) > 0 &&
-		(err.Error() == "Duplicate entry" || len(err.Error()) > 15 &&
-			err.Error()[:15] == "Duplicate entry" ||
-			stringContains(err.Error(), "Duplicate entry"))
-}
-
-func stringContains(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == mysqlErrDupEntry
 }
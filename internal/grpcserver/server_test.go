@@ -0,0 +1,43 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+func TestMapDBError(t *testing.T) {
+	dup := &mysql.MySQLError{Number: mysqlErrDupEntry, Message: "Duplicate entry 'abc' for key 'PRIMARY'"}
+	tests := []struct {
+		name    string
+		err     error
+		wantDup bool
+		want    codes.Code
+	}{
+		{"duplicate key", dup, true, codes.AlreadyExists},
+		{"wrapped duplicate key", fmt.Errorf("repo create: %w", dup), true, codes.AlreadyExists},
+		{"other mysql error", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, false, codes.Internal},
+		{"duplicate text only", errors.New("Error 1062: Duplicate entry"), false, codes.Internal},
+		{"not found", fmt.Errorf("repo getByID: %w", sql.ErrNoRows), false, codes.NotFound},
+		{"policy", fmt.Errorf("repo create: %w", repository.ErrPolicyViolation), false, codes.InvalidArgument},
+		{"timeout", fmt.Errorf("repo create: %w", context.DeadlineExceeded), false, codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateEntry(tt.err); got != tt.wantDup {
+				t.Errorf("isDuplicateEntry = %v, want %v", got, tt.wantDup)
+			}
+			if got := status.Code(mapDBError(tt.err, "RegisterFile", "abc")); got != tt.want {
+				t.Errorf("mapDBError code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
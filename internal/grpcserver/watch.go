@@ -0,0 +1,85 @@
+package grpcserver
+
+import (
+	"log/slog"
+
+	"github.com/mtiwari1/gopherdrive/internal/eventlog"
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+	pb "github.com/mtiwari1/gopherdrive/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// eventStatus maps the processing events that change a file's status to the
+// status they leave it in.
+func eventStatus(kind string) (string, bool) {
+	switch kind {
+	case "uploaded", "status_pending":
+		return repository.StatusPending, true
+	case "status_completed":
+		return repository.StatusCompleted, true
+	case "status_failed", "quarantined":
+		return repository.StatusFailed, true
+	}
+	return "", false
+}
+
+// WatchStatus streams status changes from the in-process event log. A
+// single-file watch starts with the file's current status and ends once it
+// reaches a terminal one; a watch on every file runs until the client
+// cancels. Only changes made by this instance are seen.
+func (s *Server) WatchStatus(req *pb.WatchStatusRequest, stream pb.GopherDrive_WatchStatusServer) error {
+	s.logger.Info("grpc WatchStatus", slog.String("file_id", req.Id))
+
+	if s.cfg.Events == nil {
+		return status.Error(codes.Unimplemented, "WatchStatus: event log disabled")
+	}
+	ctx := stream.Context()
+
+	// Subscribe before reading the current status so a change landing in
+	// between is still delivered.
+	updates, cancel := s.cfg.Events.Subscribe(req.Id)
+	defer cancel()
+
+	if req.Id != "" {
+		rec, err := s.repo.GetByID(ctx, req.Id)
+		if err != nil {
+			return mapDBError(err, "WatchStatus", req.Id)
+		}
+		if err := stream.Send(&pb.StatusUpdate{Id: rec.ID, Status: rec.Status, Event: "current"}); err != nil {
+			return err
+		}
+		if repository.IsTerminal(rec.Status) {
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case u := <-updates:
+			st, ok := eventStatus(u.Kind)
+			if !ok {
+				continue
+			}
+			if err := stream.Send(statusUpdate(u, st)); err != nil {
+				return err
+			}
+			if req.Id != "" && repository.IsTerminal(st) {
+				return nil
+			}
+		}
+	}
+}
+
+func statusUpdate(u eventlog.Update, st string) *pb.StatusUpdate {
+	return &pb.StatusUpdate{
+		Id:         u.FileID,
+		Status:     st,
+		Event:      u.Kind,
+		Detail:     u.Detail,
+		TimeUnixMs: u.Time.UnixMilli(),
+	}
+}
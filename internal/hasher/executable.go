@@ -0,0 +1,87 @@
+package hasher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Executable formats reported under Extra["executable_format"].
+const (
+	ExecutableELF    = "elf"
+	ExecutablePE     = "pe"
+	ExecutableMachO  = "mach-o"
+	ExecutableScript = "script"
+)
+
+// machOMagics are the thin Mach-O headers in both byte orders, 32 and 64 bit.
+var machOMagics = [][]byte{
+	{0xfe, 0xed, 0xfa, 0xce},
+	{0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe},
+	{0xcf, 0xfa, 0xed, 0xfe},
+}
+
+// execHeadSize is how much of a file executableFormat needs: the whole
+// MS-DOS header, which ends with the offset of the PE header.
+const execHeadSize = 0x40
+
+// peOffsetLimit bounds e_lfanew; the PE header follows the short DOS stub.
+const peOffsetLimit = 1 << 20
+
+// executableFormat identifies native executables and shebang scripts by
+// their leading magic bytes, returning "" for anything else. head is the
+// first execHeadSize bytes of r. It never trusts the file name or the
+// sniffed MIME type.
+func executableFormat(r io.ReaderAt, head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return ExecutableELF
+	case isPE(r, head):
+		return ExecutablePE
+	case bytes.HasPrefix(head, []byte("#!")):
+		return ExecutableScript
+	}
+	for _, magic := range machOMagics {
+		if bytes.HasPrefix(head, magic) {
+			return ExecutableMachO
+		}
+	}
+	// Universal binaries share 0xCAFEBABE with Java class files; the next
+	// word is a small architecture count in one and the class-file version
+	// (45 or later) in the other.
+	if len(head) >= 8 && bytes.HasPrefix(head, []byte{0xca, 0xfe, 0xba, 0xbe}) {
+		if n := binary.BigEndian.Uint32(head[4:8]); n > 0 && n < 45 {
+			return ExecutableMachO
+		}
+	}
+	return ""
+}
+
+// isPE reports whether the file has an MS-DOS header whose e_lfanew field
+// (offset 0x3c) points at a "PE\0\0" signature. "MZ" alone is too common at
+// the start of text files to mean anything.
+func isPE(r io.ReaderAt, head []byte) bool {
+	if len(head) < execHeadSize || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	off := binary.LittleEndian.Uint32(head[0x3c:0x40])
+	if off < execHeadSize || off > peOffsetLimit {
+		return false
+	}
+	sig := make([]byte, 4)
+	if _, err := r.ReadAt(sig, int64(off)); err != nil {
+		return false
+	}
+	return bytes.Equal(sig, []byte("PE\x00\x00"))
+}
+
+// readHead returns up to n bytes from the start of r.
+func readHead(r io.ReaderAt, n int) ([]byte, error) {
+	head := make([]byte, n)
+	m, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return head[:m], nil
+}
@@ -4,8 +4,11 @@ package hasher
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -15,7 +18,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Metadata holds computed file metadata.
@@ -26,58 +31,216 @@ type Metadata struct {
 	Extra     map[string]interface{} // Rich metadata (mime, width, height, etc.)
 }
 
-// ComputeMetadata streams the file through SHA256 and returns its metadata.
-func ComputeMetadata(filePath string) (*Metadata, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("hasher: open file: %w", err)
+// Content extractors that can be toggled per upload.
+const (
+	ExtractorImage = "image"
+	ExtractorText  = "text"
+	ExtractorSVG   = "svg"
+)
+
+// IsExtractor reports whether name is a known content extractor.
+func IsExtractor(name string) bool {
+	for _, e := range extractors {
+		if e.Name == name {
+			return true
+		}
 	}
-	defer f.Close()
+	return false
+}
+
+// Digest is the content hash, size and sniffed MIME type of a stream.
+type Digest struct {
+	Hash     string `json:"hash"` // hex-encoded digest (see HashScheme*)
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type"`
+}
+
+// ShortHash returns the first n characters of hash, or all of it if it is
+// shorter.
+func ShortHash(hash string, n int) string {
+	if n <= 0 || n >= len(hash) {
+		return hash
+	}
+	return hash[:n]
+}
+
+// HashReader streams r through SHA256 in a single pass, sniffing the MIME
+// type from the first 512 bytes. Nothing is buffered beyond that head, so it
+// works for request bodies as well as files.
+func HashReader(r io.Reader) (*Digest, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("hasher: read head: %w", err)
+	}
+	head = head[:n]
 
-	// 1. Setup SHA256 hasher
 	h := sha256.New()
+	h.Write(head)
+	rest, err := io.Copy(h, r)
+	if err != nil {
+		return nil, fmt.Errorf("hasher: copy: %w", err)
+	}
+
+	return &Digest{
+		Hash:     hex.EncodeToString(h.Sum(nil)),
+		Size:     int64(n) + rest,
+		MimeType: sniffMIME(head),
+	}, nil
+}
+
+// sniffMIME detects the MIME type from up to 512 leading bytes; shorter heads
+// are fine, the sniffer only looks at what is there. An empty file has no
+// content to classify, so it is reported as unknown rather than the
+// "text/plain" that http.DetectContentType returns for no input.
+func sniffMIME(head []byte) string {
+	if len(head) == 0 {
+		return mimeUnknown
+	}
+	return http.DetectContentType(head)
+}
+
+// Options tunes ComputeMetadata. The zero value applies no limits.
+type Options struct {
+	// AnalysisTimeout bounds the content-specific analysis step only; hashing
+	// is bounded by read speed and is not affected. Zero means no timeout.
+	AnalysisTimeout time.Duration
+
+	// TreeHashThreshold switches files of at least this many bytes to the
+	// parallel HashSchemeTree. Zero keeps plain SHA256 for every file.
+	TreeHashThreshold int64
 
-	// 2. Read first 512 bytes for MIME detection
+	// TreeHashChunkSize is the leaf size for HashSchemeTree; zero selects
+	// DefaultTreeChunkSize.
+	TreeHashChunkSize int64
+
+	// Extractors overrides which content extractors run, keyed by Extractor*
+	// name. Missing entries default to enabled. When non-nil, the applied
+	// settings are recorded under Extra["processing_options"].
+	Extractors map[string]bool
+
+	// MimeFromExtension replaces an inconclusive sniff
+	// ("application/octet-stream") with the conventional type for the file
+	// extension, when known. Inferred types are flagged with
+	// Extra["mime_sniffed"] = false.
+	MimeFromExtension bool
+
+	// ShortHashLength, when positive, also records the first that many hex
+	// characters of the hash as Extra["short_hash"] for display. The full
+	// hash stays authoritative.
+	ShortHashLength int
+}
+
+// treeChunkSize returns the configured leaf size or the default.
+func (o Options) treeChunkSize() int64 {
+	if o.TreeHashChunkSize > 0 {
+		return o.TreeHashChunkSize
+	}
+	return DefaultTreeChunkSize
+}
+
+// treeDigest sniffs the MIME type from the head of f and tree-hashes its content.
+func treeDigest(f *os.File, size, chunkSize int64) (*Digest, error) {
 	head := make([]byte, 512)
-	n, err := f.Read(head)
+	n, err := f.ReadAt(head, 0)
 	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("hasher: read head: %w", err)
 	}
 
-	mimeType := http.DetectContentType(head[:n])
+	hash, err := treeHash(f, size, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Digest{
+		Hash:     hash,
+		Size:     size,
+		MimeType: sniffMIME(head[:n]),
+	}, nil
+}
+
+// enabled reports whether the named extractor should run.
+func (o Options) enabled(name string) bool {
+	on, ok := o.Extractors[name]
+	return !ok || on
+}
 
-	// Reset file pointer depending on how much we read
-	// Actually, we can just MultiReader the head + rest of file
-	// But seeking is easier since it's a file
-	if _, err := f.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("hasher: seek: %w", err)
+// ComputeMetadata streams the file through SHA256 and returns its metadata.
+// If content analysis exceeds opts.AnalysisTimeout, the hash, size and MIME
+// type are still returned with Extra["analysis_timeout"] set to true.
+func ComputeMetadata(ctx context.Context, filePath string, opts Options) (*Metadata, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("hasher: open file: %w", err)
 	}
+	defer f.Close()
 
-	// 3. Compute Hash & Size (Stream)
-	size, err := io.Copy(h, f)
+	fi, err := f.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("hasher: copy: %w", err)
+		return nil, fmt.Errorf("hasher: stat: %w", err)
 	}
-	hash := hex.EncodeToString(h.Sum(nil))
 
-	extra := map[string]interface{}{
-		"mime_type": mimeType,
+	// 1-3. Sniff MIME type and hash. Large files use the parallel tree
+	// scheme; everything else streams SHA256 + size in one pass.
+	var digest *Digest
+	scheme := HashSchemeSHA256
+	if opts.TreeHashThreshold > 0 && fi.Size() >= opts.TreeHashThreshold {
+		scheme = HashSchemeTree
+		digest, err = treeDigest(f, fi.Size(), opts.treeChunkSize())
+	} else {
+		digest, err = HashReader(f)
+	}
+	if err != nil {
+		return nil, err
 	}
+	hash, size, mimeType := digest.Hash, digest.Size, digest.MimeType
 
-	// 4. Content-Specific Analysis
-	// Re-open file for specific analysis to avoid seek issues or complex readers
-	if strings.HasPrefix(mimeType, "image/") {
-		if imgArgs, err := analyzeImage(filePath); err == nil {
-			for k, v := range imgArgs {
-				extra[k] = v
-			}
+	extra := map[string]interface{}{}
+	// An empty file says nothing about its format, whatever its name.
+	if opts.MimeFromExtension && mimeType == mimeUnknown && size > 0 {
+		if inferred := mimeFromExtension(filePath); inferred != "" {
+			mimeType = inferred
+			extra["mime_sniffed"] = false
 		}
-	} else if strings.HasPrefix(mimeType, "text/") {
-		if txtArgs, err := analyzeText(filePath); err == nil {
-			for k, v := range txtArgs {
-				extra[k] = v
-			}
+	}
+	extra["mime_type"] = mimeType
+
+	head, err := readHead(f, execHeadSize)
+	if err != nil {
+		return nil, fmt.Errorf("hasher: read head: %w", err)
+	}
+	if format := executableFormat(f, head); format != "" {
+		extra["executable"] = true
+		extra["executable_format"] = format
+	}
+
+	if opts.ShortHashLength > 0 {
+		extra["short_hash"] = ShortHash(hash, opts.ShortHashLength)
+	}
+	if scheme == HashSchemeTree {
+		extra["hash_scheme"] = scheme
+		extra["hash_chunk_size"] = opts.treeChunkSize()
+	}
+
+	// 4. Content-Specific Analysis, under its own deadline. Analyzers rewind
+	// and reuse f rather than opening the file again.
+	analysis, err := runAnalysis(ctx, opts.AnalysisTimeout, func(ctx context.Context) map[string]interface{} {
+		return analyzeContent(ctx, f, filePath, mimeType, opts)
+	})
+	switch {
+	case errors.Is(err, errAnalysisTimeout):
+		extra["analysis_timeout"] = true
+	case err != nil:
+		return nil, fmt.Errorf("hasher: analyze: %w", err)
+	}
+	for k, v := range analysis {
+		extra[k] = v
+	}
+	if opts.Extractors != nil {
+		applied := make(map[string]bool, len(extractors))
+		for _, e := range extractors {
+			applied[e.Name] = opts.enabled(e.Name)
 		}
+		extra["processing_options"] = applied
 	}
 
 	return &Metadata{
@@ -88,34 +251,131 @@ func ComputeMetadata(filePath string) (*Metadata, error) {
 	}, nil
 }
 
-func analyzeImage(path string) (map[string]interface{}, error) {
+// errAnalysisTimeout reports that content analysis exceeded its deadline.
+var errAnalysisTimeout = errors.New("hasher: content analysis timed out")
+
+// runAnalysis runs fn with a deadline of timeout (none if zero). Analyzers
+// that cannot observe ctx keep running in the background after a timeout;
+// their result is discarded.
+func runAnalysis(ctx context.Context, timeout time.Duration, fn func(context.Context) map[string]interface{}) (map[string]interface{}, error) {
+	if timeout <= 0 {
+		return fn(ctx), nil
+	}
+
+	actx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan map[string]interface{}, 1) // buffered so a late analyzer never blocks
+	go func() { done <- fn(actx) }()
+
+	select {
+	case res := <-done:
+		return res, nil
+	case <-actx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errAnalysisTimeout
+	}
+}
+
+// analyzeContent dispatches to the analyzer for mimeType, reading from r;
+// filePath only informs the dispatch. Analyzer failures are not fatal: the
+// file simply gets no content-specific fields.
+func analyzeContent(ctx context.Context, r io.ReadSeeker, filePath, mimeType string, opts Options) map[string]interface{} {
+	extra := map[string]interface{}{}
+
+	// SVGs sniff as generic XML (or text); confirm by the root tag.
+	var svgArgs map[string]interface{}
+	if opts.enabled(ExtractorSVG) && (isXMLMime(mimeType) || isSVGExt(filePath)) {
+		svgArgs, _ = analyzeSVGReader(r)
+	}
+
+	if svgArgs != nil {
+		extra["mime_type"] = "image/svg+xml"
+		for k, v := range svgArgs {
+			extra[k] = v
+		}
+	} else if strings.HasPrefix(mimeType, "image/") && opts.enabled(ExtractorImage) {
+		if imgArgs, err := analyzeImageReader(r); err == nil {
+			for k, v := range imgArgs {
+				extra[k] = v
+			}
+		}
+	} else if strings.HasPrefix(mimeType, "text/") && opts.enabled(ExtractorText) {
+		if txtArgs, err := analyzeTextReader(ctx, r); err == nil {
+			for k, v := range txtArgs {
+				extra[k] = v
+			}
+		}
+	}
+	return extra
+}
+
+// openAndAnalyze opens path and runs fn on it; it backs the path-based
+// analyzer wrappers.
+func openAndAnalyze(path string, fn func(io.ReadSeeker) (map[string]interface{}, error)) (map[string]interface{}, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
+	return fn(f)
+}
 
-	cfg, _, err := image.DecodeConfig(f)
-	if err != nil {
+func analyzeImage(path string) (map[string]interface{}, error) {
+	return openAndAnalyze(path, analyzeImageReader)
+}
+
+// analyzeImageReader decodes the image header from the start of r. A file
+// whose signature matches a registered decoder but whose header does not
+// decode is reported as image_corrupt with the detected format; a format
+// with no decoder (WebP, BMP…) is reported as image_supported=false.
+func analyzeImageReader(r io.ReadSeeker) (map[string]interface{}, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
+
+	// DecodeConfig names the sniffed format even when decoding fails.
+	cfg, format, err := image.DecodeConfig(r)
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		return map[string]interface{}{
+			"image_supported": false,
+		}, nil
+	case err != nil:
+		return map[string]interface{}{
+			"image_format":  format,
+			"image_corrupt": true,
+			"image_error":   err.Error(),
+		}, nil
+	}
 	return map[string]interface{}{
-		"width":  cfg.Width,
-		"height": cfg.Height,
+		"image_format": format,
+		"width":        cfg.Width,
+		"height":       cfg.Height,
 	}, nil
 }
 
-func analyzeText(path string) (map[string]interface{}, error) {
-	f, err := os.Open(path)
-	if err != nil {
+func analyzeText(ctx context.Context, path string) (map[string]interface{}, error) {
+	return openAndAnalyze(path, func(r io.ReadSeeker) (map[string]interface{}, error) {
+		return analyzeTextReader(ctx, r)
+	})
+}
+
+// analyzeTextReader counts lines and words from the start of r.
+func analyzeTextReader(ctx context.Context, r io.ReadSeeker) (map[string]interface{}, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
 	lines := 0
 	words := 0
 	for scanner.Scan() {
+		if lines%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		lines++
 		words += len(bytes.Fields(scanner.Bytes()))
 	}
@@ -124,3 +384,127 @@ func analyzeText(path string) (map[string]interface{}, error) {
 		"words": words,
 	}, nil
 }
+
+// errNotSVG is returned by analyzeSVG when the document root is not <svg>.
+var errNotSVG = errors.New("hasher: not an svg document")
+
+// isXMLMime reports whether a sniffed MIME type may be an SVG document.
+func isXMLMime(mimeType string) bool {
+	mt, _, _ := strings.Cut(mimeType, ";")
+	switch strings.TrimSpace(mt) {
+	case "text/xml", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// isSVGExt reports whether the path carries an .svg extension.
+func isSVGExt(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".svg")
+}
+
+// analyzeSVG reads the root <svg> element's width, height and viewBox and
+// counts all elements. A document that breaks off after the root is reported
+// with "svg_malformed" rather than failing.
+func analyzeSVG(path string) (map[string]interface{}, error) {
+	return openAndAnalyze(path, analyzeSVGReader)
+}
+
+// analyzeSVGReader is analyzeSVG reading from the start of r.
+func analyzeSVGReader(r io.ReadSeeker) (map[string]interface{}, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	dec := xml.NewDecoder(bufio.NewReader(r))
+	dec.Strict = false
+
+	// Find the root element, skipping the prolog, comments and doctype.
+	var root xml.StartElement
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("hasher: svg root: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			root = se
+			break
+		}
+	}
+	if !strings.EqualFold(root.Name.Local, "svg") {
+		return nil, errNotSVG
+	}
+
+	out := map[string]interface{}{}
+	var viewBox []float64
+	for _, attr := range root.Attr {
+		switch attr.Name.Local {
+		case "width":
+			if v, ok := parseSVGLength(attr.Value); ok {
+				out["width"] = v
+			}
+		case "height":
+			if v, ok := parseSVGLength(attr.Value); ok {
+				out["height"] = v
+			}
+		case "viewBox":
+			out["view_box"] = attr.Value
+			viewBox = parseViewBox(attr.Value)
+		}
+	}
+	// Fall back to the viewBox for dimensions missing or given in relative units.
+	if len(viewBox) == 4 {
+		if _, ok := out["width"]; !ok {
+			out["width"] = viewBox[2]
+		}
+		if _, ok := out["height"]; !ok {
+			out["height"] = viewBox[3]
+		}
+	}
+
+	elements := 1 // the root
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out["svg_malformed"] = true
+			break
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			elements++
+		}
+	}
+	out["elements"] = elements
+
+	return out, nil
+}
+
+// parseSVGLength parses absolute lengths such as "120" or "120px". Relative
+// units (%, em) cannot be resolved without a viewport and are rejected.
+func parseSVGLength(s string) (float64, bool) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "px")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseViewBox parses "min-x min-y width height", separated by spaces and/or commas.
+func parseViewBox(s string) []float64 {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' || r == '\n' })
+	if len(fields) != 4 {
+		return nil
+	}
+	out := make([]float64, 0, 4)
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil
+		}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package hasher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes content to a file named name in a temp dir.
+func writeTestFile(tb testing.TB, name string, content []byte) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// tinyPNG encodes a 1x1 image, well under the 512-byte sniff window.
+func tinyPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSmallFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		mime    string
+		extra   map[string]interface{} // keys ComputeMetadata must set
+	}{
+		{"empty", "empty.txt", nil, mimeUnknown, nil},
+		{"one byte", "one.txt", []byte("a"), "text/plain; charset=utf-8", map[string]interface{}{"lines": 1, "words": 1}},
+		{"ten byte text", "ten.txt", []byte("hello you\n"), "text/plain; charset=utf-8", map[string]interface{}{"lines": 1, "words": 2}},
+		{"sub-512 text", "short.txt", []byte(strings.Repeat("ab cd\n", 50)), "text/plain; charset=utf-8", map[string]interface{}{"lines": 50, "words": 100}},
+		{"sub-512 png", "dot.png", tinyPNG(t), "image/png", map[string]interface{}{"image_format": "png", "width": 1, "height": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := HashReader(bytes.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("HashReader: %v", err)
+			}
+			if d.Size != int64(len(tt.content)) || d.Hash != sha256Hex(tt.content) || d.MimeType != tt.mime {
+				t.Errorf("HashReader = %+v, want size %d, hash %s, mime %s", d, len(tt.content), sha256Hex(tt.content), tt.mime)
+			}
+
+			meta, err := ComputeMetadata(context.Background(), writeTestFile(t, tt.file, tt.content), Options{})
+			if err != nil {
+				t.Fatalf("ComputeMetadata: %v", err)
+			}
+			if meta.Size != d.Size || meta.Hash != d.Hash {
+				t.Errorf("ComputeMetadata size %d hash %s, want %d %s", meta.Size, meta.Hash, d.Size, d.Hash)
+			}
+			if got := meta.Extra["mime_type"]; got != tt.mime {
+				t.Errorf("mime_type = %v, want %s", got, tt.mime)
+			}
+			for k, want := range tt.extra {
+				if got := meta.Extra[k]; got != want {
+					t.Errorf("%s = %v, want %v", k, got, want)
+				}
+			}
+			if _, ok := meta.Extra["image_corrupt"]; ok {
+				t.Errorf("small file reported as a corrupt image: %v", meta.Extra)
+			}
+		})
+	}
+}
+
+// BenchmarkAnalyzeText compares reopening the file by path, as analyzers did
+// before, with reusing the handle ComputeMetadata already holds. The
+// difference is one open and close per analyzed file.
+func BenchmarkAnalyzeText(b *testing.B) {
+	path := writeTestFile(b, "words.txt", []byte(strings.Repeat("the quick brown fox\n", 64)))
+	ctx := context.Background()
+
+	b.Run("reopen", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := analyzeText(ctx, path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reuse", func(b *testing.B) {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := analyzeTextReader(ctx, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkComputeMetadata(b *testing.B) {
+	path := writeTestFile(b, "words.txt", []byte(strings.Repeat("the quick brown fox\n", 64)))
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ComputeMetadata(ctx, path, Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package hasher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// mimeUnknown is what http.DetectContentType returns for content it cannot
+// identify.
+const mimeUnknown = "application/octet-stream"
+
+// extensionMimes maps extensions of common binary formats that the standard
+// sniffer cannot detect to their conventional MIME type. It is kept in code
+// rather than read from the system MIME database so results do not vary
+// between hosts.
+var extensionMimes = map[string]string{
+	".7z":      "application/x-7z-compressed",
+	".aac":     "audio/aac",
+	".avro":    "application/avro",
+	".bz2":     "application/x-bzip2",
+	".deb":     "application/vnd.debian.binary-package",
+	".dmg":     "application/x-apple-diskimage",
+	".dll":     "application/vnd.microsoft.portable-executable",
+	".exe":     "application/vnd.microsoft.portable-executable",
+	".flac":    "audio/flac",
+	".heic":    "image/heic",
+	".heif":    "image/heif",
+	".iso":     "application/x-iso9660-image",
+	".jxl":     "image/jxl",
+	".mkv":     "video/x-matroska",
+	".msi":     "application/x-msi",
+	".parquet": "application/vnd.apache.parquet",
+	".psd":     "image/vnd.adobe.photoshop",
+	".rpm":     "application/x-rpm",
+	".sqlite":  "application/vnd.sqlite3",
+	".tar":     "application/x-tar",
+	".xz":      "application/x-xz",
+	".zst":     "application/zstd",
+}
+
+// mimeFromExtension returns the conventional MIME type for filePath's
+// extension, or "" if the extension is not in extensionMimes.
+func mimeFromExtension(filePath string) string {
+	return extensionMimes[strings.ToLower(filepath.Ext(filePath))]
+}
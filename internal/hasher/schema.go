@@ -0,0 +1,95 @@
+package hasher
+
+// Field describes one key a file's metadata (Metadata.Extra) may contain.
+type Field struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"` // JSON type: string, integer, number, boolean or object
+	Description string `json:"description"`
+}
+
+// ExtractorInfo describes a content extractor: the MIME types it runs for
+// ("type/*" matches a whole family) and the keys it may add.
+type ExtractorInfo struct {
+	Name      string   `json:"name"`
+	MIMETypes []string `json:"mime_types"`
+	Fields    []Field  `json:"fields"`
+}
+
+// extractors is the registry of content extractors, in the order
+// analyzeContent tries them. Keep it in step with the analyzers: it backs
+// IsExtractor, the recorded processing options and GET /metadata-schema.
+var extractors = []ExtractorInfo{
+	{
+		Name: ExtractorSVG,
+		// Also tried for any *.svg file; the root element decides.
+		MIMETypes: []string{"image/svg+xml", "text/xml", "application/xml"},
+		Fields: []Field{
+			{"width", "number", "width in user units, from the width attribute or the viewBox"},
+			{"height", "number", "height in user units, from the height attribute or the viewBox"},
+			{"view_box", "string", "the raw viewBox attribute"},
+			{"elements", "integer", "number of elements, including the root"},
+			{"svg_malformed", "boolean", "set when the document stops parsing after the root"},
+		},
+	},
+	{
+		Name:      ExtractorImage,
+		MIMETypes: []string{"image/*"},
+		Fields: []Field{
+			{"image_format", "string", "decoder that recognised the image (gif, jpeg, png)"},
+			{"width", "integer", "width in pixels"},
+			{"height", "integer", "height in pixels"},
+			{"image_supported", "boolean", "false when no decoder handles the format"},
+			{"image_corrupt", "boolean", "set when the header matches a decoder but does not decode"},
+			{"image_error", "string", "decoder error for a corrupt image"},
+		},
+	},
+	{
+		Name:      ExtractorText,
+		MIMETypes: []string{"text/*"},
+		Fields: []Field{
+			{"lines", "integer", "number of lines"},
+			{"words", "integer", "number of whitespace-separated words"},
+		},
+	},
+}
+
+// commonFields are keys ComputeMetadata may set whatever the file type.
+var commonFields = []Field{
+	{"mime_type", "string", "detected MIME type"},
+	{"mime_sniffed", "boolean", "false when the type was inferred from the extension"},
+	{"executable", "boolean", "set for ELF, PE, Mach-O and shebang files"},
+	{"executable_format", "string", "elf, pe, mach-o or script"},
+	{"hash_scheme", "string", "set when the hash is not plain SHA-256"},
+	{"hash_chunk_size", "integer", "leaf size of a tree hash"},
+	{"short_hash", "string", "display prefix of the hash"},
+	{"analysis_timeout", "boolean", "set when content analysis exceeded its deadline"},
+	{"processing_options", "object", "extractor toggles applied to the upload"},
+}
+
+// Extractors returns the content extractor registry.
+func Extractors() []ExtractorInfo {
+	return append([]ExtractorInfo(nil), extractors...)
+}
+
+// CommonFields returns the metadata keys not tied to an extractor.
+func CommonFields() []Field {
+	return append([]Field(nil), commonFields...)
+}
+
+// IsComputedKey reports whether processing may set key, either for every
+// file or from some extractor.
+func IsComputedKey(key string) bool {
+	for _, f := range commonFields {
+		if f.Key == key {
+			return true
+		}
+	}
+	for _, e := range extractors {
+		for _, f := range e.Fields {
+			if f.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
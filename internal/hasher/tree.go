@@ -0,0 +1,80 @@
+package hasher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+const (
+	// HashSchemeSHA256 is the default whole-file SHA256.
+	HashSchemeSHA256 = "sha256"
+
+	// HashSchemeTree is SHA256 over the concatenated SHA256 digests of
+	// fixed-size chunks, in order. Chunks are hashed in parallel.
+	HashSchemeTree = "sha256-tree"
+
+	// DefaultTreeChunkSize is used when Options.TreeHashChunkSize is zero.
+	DefaultTreeChunkSize = 8 << 20 // 8 MB
+)
+
+// treeHash computes the HashSchemeTree digest of the first size bytes of r,
+// hashing chunkSize pieces on up to GOMAXPROCS goroutines.
+func treeHash(r io.ReaderAt, size, chunkSize int64) (string, error) {
+	chunks := int((size + chunkSize - 1) / chunkSize)
+	if chunks == 0 {
+		chunks = 1 // an empty file still has one (empty) leaf
+	}
+	leaves := make([][sha256.Size]byte, chunks)
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > chunks {
+		workers = chunks
+	}
+
+	next := make(chan int)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := sha256.New()
+			for i := range next {
+				off := int64(i) * chunkSize
+				n := chunkSize
+				if off+n > size {
+					n = size - off
+				}
+				h.Reset()
+				if _, err := io.Copy(h, io.NewSectionReader(r, off, n)); err != nil {
+					errs <- fmt.Errorf("hasher: tree chunk %d: %w", i, err)
+					// Keep draining so the producer never blocks.
+					for range next {
+					}
+					return
+				}
+				h.Sum(leaves[i][:0])
+			}
+		}()
+	}
+
+	for i := 0; i < chunks; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return "", err
+	}
+
+	root := sha256.New()
+	for i := range leaves {
+		root.Write(leaves[i][:])
+	}
+	return hex.EncodeToString(root.Sum(nil)), nil
+}
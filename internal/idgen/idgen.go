@@ -0,0 +1,40 @@
+// Package idgen generates file IDs.
+package idgen
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Generator produces unique file IDs.
+type Generator interface {
+	NewID() string
+}
+
+// UUIDv4 generates random UUIDs. IDs carry no ordering.
+type UUIDv4 struct{}
+
+// NewID returns a new version 4 UUID.
+func (UUIDv4) NewID() string { return uuid.New().String() }
+
+// UUIDv7 generates UUIDs that start with a millisecond timestamp, so IDs
+// sort by creation time and ID-ordered pages come out chronologically.
+type UUIDv7 struct{}
+
+// NewID returns a new version 7 UUID. Like uuid.New it panics only if the
+// system random source fails.
+func (UUIDv7) NewID() string { return uuid.Must(uuid.NewV7()).String() }
+
+// ByName returns the generator for a format name: "uuidv4" (also the empty
+// string) or "uuidv7".
+func ByName(name string) (Generator, error) {
+	switch name {
+	case "", "uuidv4":
+		return UUIDv4{}, nil
+	case "uuidv7":
+		return UUIDv7{}, nil
+	default:
+		return nil, fmt.Errorf("unknown ID format %q (want uuidv4 or uuidv7)", name)
+	}
+}
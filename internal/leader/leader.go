@@ -0,0 +1,65 @@
+// Package leader elects one instance among replicas to run singleton
+// background tasks, using a lease held in the database.
+package leader
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// Elector campaigns for a named lock and tracks whether this instance holds it.
+type Elector struct {
+	lock   repository.LeaderLock
+	name   string
+	id     string
+	lease  time.Duration
+	logger *slog.Logger
+
+	leader atomic.Bool
+}
+
+// NewElector creates an Elector for the lock name, identifying this instance
+// as id. Call Run to start campaigning.
+func NewElector(lock repository.LeaderLock, name, id string, lease time.Duration, logger *slog.Logger) *Elector {
+	return &Elector{lock: lock, name: name, id: id, lease: lease, logger: logger}
+}
+
+// IsLeader reports whether this instance currently holds the lease.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run renews or contends for the lease every third of its length until ctx is
+// cancelled, then releases it so another instance can take over immediately.
+// A failed renewal drops leadership at once rather than risk two leaders.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		ok, err := e.lock.TryAcquire(ctx, e.name, e.id, e.lease)
+		if err != nil && ctx.Err() == nil {
+			e.logger.Error("leader heartbeat", slog.String("lock", e.name), slog.String("error", err.Error()))
+		}
+		if was := e.leader.Swap(ok && err == nil); was != e.leader.Load() {
+			e.logger.Info("leadership changed", slog.String("lock", e.name), slog.String("instance", e.id), slog.Bool("leader", !was))
+		}
+
+		select {
+		case <-ctx.Done():
+			if e.leader.Swap(false) {
+				relCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				if err := e.lock.Release(relCtx, e.name, e.id); err != nil {
+					e.logger.Warn("leader release", slog.String("lock", e.name), slog.String("error", err.Error()))
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
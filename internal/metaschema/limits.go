@@ -0,0 +1,51 @@
+package metaschema
+
+import "fmt"
+
+// Limits bounds the shape of client-supplied metadata so it cannot bloat
+// the metadata column. Zero fields are unlimited.
+type Limits struct {
+	// MaxKeys caps the number of object keys at all nesting levels.
+	MaxKeys int
+
+	// MaxDepth caps nesting; a flat object has depth 1 and each nested
+	// object or array adds one.
+	MaxDepth int
+}
+
+// Check reports an error describing the first limit meta exceeds.
+func (l Limits) Check(meta map[string]interface{}) error {
+	keys, depth := measure(meta, 1)
+	if l.MaxKeys > 0 && keys > l.MaxKeys {
+		return fmt.Errorf("metadata has %d keys, limit is %d", keys, l.MaxKeys)
+	}
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return fmt.Errorf("metadata nests %d levels deep, limit is %d", depth, l.MaxDepth)
+	}
+	return nil
+}
+
+// measure returns the key count and maximum depth of v, which sits at
+// nesting level level.
+func measure(v interface{}, level int) (keys, depth int) {
+	depth = level
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys = len(val)
+		for _, child := range val {
+			k, d := measure(child, level+1)
+			keys += k
+			depth = max(depth, d)
+		}
+	case []interface{}:
+		for _, child := range val {
+			k, d := measure(child, level+1)
+			keys += k
+			depth = max(depth, d)
+		}
+	default:
+		// Scalars sit inside their container and add no level.
+		depth = level - 1
+	}
+	return keys, depth
+}
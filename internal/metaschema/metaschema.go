@@ -0,0 +1,172 @@
+// Package metaschema checks file metadata against a JSON Schema before it is
+// persisted. Only the subset needed to keep the metadata column predictable
+// is supported: "type", "properties", "additionalProperties" and "items".
+// Other keywords are accepted and ignored. Limits separately bounds the key
+// count and nesting of client-supplied metadata.
+package metaschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Schema is a parsed JSON Schema node.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+var knownTypes = map[string]bool{
+	"": true, "object": true, "array": true, "string": true,
+	"number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// Load reads and parses a schema file. The root must describe an object.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("metaschema: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a schema document. The root must describe an object.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("metaschema: %w", err)
+	}
+	if s.Type != "" && s.Type != "object" {
+		return nil, fmt.Errorf("metaschema: root type must be object, got %q", s.Type)
+	}
+	if err := s.check("$"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) check(path string) error {
+	if !knownTypes[s.Type] {
+		return fmt.Errorf("metaschema: %s: unsupported type %q", path, s.Type)
+	}
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("metaschema: %s.%s: empty schema", path, name)
+		}
+		if err := p.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// Sanitize returns a copy of meta with every value that violates the schema
+// removed, plus a description of each violation. Values are normalised
+// through JSON first, so the result matches what the database stores. A nil
+// Schema returns meta unchanged.
+func (s *Schema) Sanitize(meta map[string]interface{}) (map[string]interface{}, []string) {
+	if s == nil || meta == nil {
+		return meta, nil
+	}
+
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return map[string]interface{}{}, []string{"$: not serialisable: " + err.Error()}
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return map[string]interface{}{}, []string{"$: " + err.Error()}
+	}
+
+	var violations []string
+	out := s.sanitizeObject("$", doc, &violations)
+	return out, violations
+}
+
+func (s *Schema) sanitizeObject(path string, obj map[string]interface{}, violations *[]string) map[string]interface{} {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // stable violation order
+
+	out := make(map[string]interface{}, len(obj))
+	for _, k := range keys {
+		p := path + "." + k
+		prop, ok := s.Properties[k]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*violations = append(*violations, p+": not allowed")
+				continue
+			}
+			out[k] = obj[k]
+			continue
+		}
+		if v, ok := prop.sanitize(p, obj[k], violations); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// sanitize returns the cleaned value and false if it must be dropped.
+func (s *Schema) sanitize(path string, v interface{}, violations *[]string) (interface{}, bool) {
+	if !matchesType(s.Type, v) {
+		*violations = append(*violations, fmt.Sprintf("%s: want %s, got %s", path, s.Type, typeOf(v)))
+		return nil, false
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return s.sanitizeObject(path, val, violations), true
+	case []interface{}:
+		if s.Items == nil {
+			return val, true
+		}
+		out := make([]interface{}, 0, len(val))
+		for i, item := range val {
+			if cleaned, ok := s.Items.sanitize(fmt.Sprintf("%s[%d]", path, i), item, violations); ok {
+				out = append(out, cleaned)
+			}
+		}
+		return out, true
+	}
+	return v, true
+}
+
+func matchesType(want string, v interface{}) bool {
+	if want == "" {
+		return true
+	}
+	if want == "integer" {
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	}
+	return typeOf(v) == want
+}
+
+// typeOf names the JSON type of a value decoded by encoding/json.
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,38 @@
+package metaschema
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mtiwari1/gopherdrive/internal/hasher"
+)
+
+// resultKeys are set by the results handler rather than the hasher.
+var resultKeys = map[string]bool{
+	"inferred_extension":  true,
+	"original_extension":  true,
+	"corrected_extension": true,
+}
+
+// ReservedKey reports whether key belongs to the server: processing
+// computes it, so a client may not set it and a stored value for it is never
+// carried over as client metadata.
+func ReservedKey(key string) bool {
+	return hasher.IsComputedKey(key) || resultKeys[key]
+}
+
+// CheckClientKeys rejects client metadata that sets reserved keys, naming
+// them all.
+func CheckClientKeys(meta map[string]interface{}) error {
+	var reserved []string
+	for k := range meta {
+		if ReservedKey(k) {
+			reserved = append(reserved, k)
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("metadata keys %q are computed by the server and cannot be set", reserved)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package mimestats counts processed uploads and bytes by MIME category, to
+// show which kinds of files dominate storage.
+package mimestats
+
+import (
+	"strings"
+	"sync"
+)
+
+// categories are the top-level MIME types counted individually. Anything
+// else is reported as "other", and a missing type as "unknown", so the
+// number of series stays fixed whatever clients upload.
+var categories = map[string]bool{
+	"application": true,
+	"audio":       true,
+	"font":        true,
+	"image":       true,
+	"model":       true,
+	"text":        true,
+	"video":       true,
+}
+
+// Category normalizes a MIME type such as "image/png; q=1" to its top-level
+// category ("image").
+func Category(mimeType string) string {
+	top, _, _ := strings.Cut(mimeType, "/")
+	top = strings.ToLower(strings.TrimSpace(top))
+	switch {
+	case top == "":
+		return "unknown"
+	case categories[top]:
+		return top
+	default:
+		return "other"
+	}
+}
+
+// Volume is the upload count and total size for one category and status.
+type Volume struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+type key struct{ category, status string }
+
+// Counter accumulates Volume per category and status. A nil *Counter
+// ignores observations and reports nothing.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[key]*Volume
+}
+
+// New returns an empty Counter.
+func New() *Counter {
+	return &Counter{counts: make(map[key]*Volume)}
+}
+
+// Observe records one processed file of mimeType ending in status.
+func (c *Counter) Observe(mimeType, status string, size int64) {
+	if c == nil {
+		return
+	}
+	k := key{Category(mimeType), status}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v := c.counts[k]
+	if v == nil {
+		v = &Volume{}
+		c.counts[k] = v
+	}
+	v.Files++
+	v.Bytes += size
+}
+
+// Snapshot returns the counts keyed by category, then status.
+func (c *Counter) Snapshot() map[string]map[string]Volume {
+	out := map[string]map[string]Volume{}
+	if c == nil {
+		return out
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range c.counts {
+		if out[k.category] == nil {
+			out[k.category] = map[string]Volume{}
+		}
+		out[k.category][k.status] = *v
+	}
+	return out
+}
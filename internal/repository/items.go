@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// itemInsertBatch caps the rows per INSERT when storing metadata items.
+const itemInsertBatch = 500
+
+// MetadataItem is one element of a list-valued metadata key that was moved
+// out of the metadata column into file_metadata_items.
+type MetadataItem struct {
+	Key   string          `json:"key"`
+	Index int             `json:"index"`
+	Value json.RawMessage `json:"value"`
+}
+
+// SplitListMetadata moves list values longer than limit out of meta. In the
+// returned copy each such key becomes {"count", "sample", "spilled": true},
+// with the first sample elements; spilled holds the full lists by key. meta
+// is not modified, and spilled is nil when nothing exceeds limit.
+func SplitListMetadata(meta map[string]interface{}, limit, sample int) (inline map[string]interface{}, spilled map[string][]interface{}) {
+	for k, v := range meta {
+		list, ok := listValue(v)
+		if !ok || len(list) <= limit {
+			continue
+		}
+		if spilled == nil {
+			spilled = map[string][]interface{}{}
+			inline = make(map[string]interface{}, len(meta))
+			for k2, v2 := range meta {
+				inline[k2] = v2
+			}
+		}
+		spilled[k] = list
+		inline[k] = map[string]interface{}{
+			"count":   len(list),
+			"sample":  list[:min(sample, len(list))],
+			"spilled": true,
+		}
+	}
+	if spilled == nil {
+		return meta, nil
+	}
+	return inline, spilled
+}
+
+// listValue returns v as a generic list if it is a slice of any kind other
+// than bytes, as extractors build typed slices and decoded JSON []interface{}.
+func listValue(v interface{}) ([]interface{}, bool) {
+	if list, ok := v.([]interface{}); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// ReplaceMetadataItems stores the spilled lists for a file, replacing any
+// earlier items for the same keys, in one transaction.
+func (r *MySQLRepo) ReplaceMetadataItems(ctx context.Context, fileID string, lists map[string][]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repo replaceMetadataItems begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	for key, list := range lists {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM file_metadata_items WHERE file_id = ? AND item_key = ?", fileID, key); err != nil {
+			return fmt.Errorf("repo replaceMetadataItems delete: %w", err)
+		}
+		for start := 0; start < len(list); start += itemInsertBatch {
+			end := min(start+itemInsertBatch, len(list))
+			args := make([]any, 0, (end-start)*4)
+			for i := start; i < end; i++ {
+				value, err := json.Marshal(list[i])
+				if err != nil {
+					return fmt.Errorf("repo replaceMetadataItems marshal: %w", err)
+				}
+				args = append(args, fileID, key, i, value)
+			}
+			placeholders := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?),", end-start), ",")
+			if _, err := tx.ExecContext(ctx, "INSERT INTO file_metadata_items (file_id, item_key, idx, value) VALUES "+placeholders, args...); err != nil {
+				return fmt.Errorf("repo replaceMetadataItems insert: %w", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repo replaceMetadataItems commit: %w", err)
+	}
+	return nil
+}
+
+// ListMetadataItems returns a page of a file's spilled metadata items in key
+// and index order. An empty key covers every key.
+func (r *MySQLRepo) ListMetadataItems(ctx context.Context, fileID, key string, limit, offset int) ([]MetadataItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := "SELECT item_key, idx, value FROM file_metadata_items WHERE file_id = ?"
+	args := []any{fileID}
+	if key != "" {
+		query += " AND item_key = ?"
+		args = append(args, key)
+	}
+	query += " ORDER BY item_key, idx LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo listMetadataItems: %w", err)
+	}
+	defer rows.Close()
+
+	items := []MetadataItem{}
+	for rows.Next() {
+		var (
+			it    MetadataItem
+			value []byte
+		)
+		if err := rows.Scan(&it.Key, &it.Index, &value); err != nil {
+			return nil, fmt.Errorf("repo listMetadataItems scan: %w", err)
+		}
+		it.Value = value
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repo listMetadataItems rows: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueuedJob is a processing job persisted in the durable queue.
+type QueuedJob struct {
+	ID         int64
+	FileID     string
+	FilePath   string
+	Extractors map[string]bool // nil means the defaults
+	Epoch      int64           // the file's epoch, bumped by Claim
+	Token      string          // the claim token, set by Claim
+}
+
+// JobQueue is a durable job queue shared by every server instance.
+type JobQueue interface {
+	// Enqueue persists job and sets its ID.
+	Enqueue(ctx context.Context, job *QueuedJob) error
+
+	// Claim takes the oldest unclaimed job, or one whose claim lease has
+	// expired. Returns sql.ErrNoRows when the queue is empty.
+	Claim(ctx context.Context) (*QueuedJob, error)
+
+	// Renew restarts the lease on a job claimed with token. Returns
+	// ErrClaimLost if the claim is no longer held.
+	Renew(ctx context.Context, id int64, token string) error
+
+	// Complete removes a finished job from the queue.
+	Complete(ctx context.Context, id int64) error
+}
+
+// MySQLJobQueue implements JobQueue on the jobs table using a claim-by-update
+// pattern, so concurrent instances never claim the same job. A claim that is
+// not completed within the lease (for example because its instance crashed)
+// becomes claimable again.
+type MySQLJobQueue struct {
+	instanceID string
+	lease      time.Duration
+
+	stmtEnqueue  *sql.Stmt
+	stmtClaim    *sql.Stmt
+	stmtClaimed  *sql.Stmt
+	stmtRenew    *sql.Stmt
+	stmtComplete *sql.Stmt
+	stmtEpoch    *sql.Stmt
+}
+
+// NewMySQLJobQueue prepares the queue statements. instanceID is recorded in
+// claimed_by for operators; lease bounds how long a claim stays exclusive.
+func NewMySQLJobQueue(db *sql.DB, instanceID string, lease time.Duration) (*MySQLJobQueue, error) {
+	stmtEnqueue, err := db.Prepare("INSERT INTO jobs (file_id, file_path, extractors) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("prepare enqueue: %w", err)
+	}
+
+	// MySQL allows ORDER BY ... LIMIT on single-table UPDATEs; the row lock
+	// taken by the UPDATE makes the claim atomic across instances.
+	stmtClaim, err := db.Prepare(`UPDATE jobs
+		SET status = 'claimed', claimed_by = ?, claim_token = ?, claimed_at = UTC_TIMESTAMP()
+		WHERE status = 'pending'
+			OR (status = 'claimed' AND claimed_at < UTC_TIMESTAMP() - INTERVAL ? SECOND)
+		ORDER BY id
+		LIMIT 1`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare claim: %w", err)
+	}
+
+	stmtClaimed, err := db.Prepare("SELECT id, file_id, file_path, extractors FROM jobs WHERE claim_token = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare claimed: %w", err)
+	}
+
+	stmtRenew, err := db.Prepare(`UPDATE jobs SET claimed_at = UTC_TIMESTAMP()
+		WHERE id = ? AND claim_token = ? AND status = 'claimed'`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare renew: %w", err)
+	}
+
+	stmtComplete, err := db.Prepare("DELETE FROM jobs WHERE id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare complete: %w", err)
+	}
+
+	// Claiming starts a new processing epoch for the file, so a result
+	// from a claim whose lease lapsed is rejected (see Repository.BeginProcessing).
+	stmtEpoch, err := db.Prepare("UPDATE files SET epoch = LAST_INSERT_ID(epoch + 1) WHERE id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare epoch: %w", err)
+	}
+
+	return &MySQLJobQueue{
+		instanceID:   instanceID,
+		lease:        lease,
+		stmtEnqueue:  stmtEnqueue,
+		stmtClaim:    stmtClaim,
+		stmtClaimed:  stmtClaimed,
+		stmtRenew:    stmtRenew,
+		stmtComplete: stmtComplete,
+		stmtEpoch:    stmtEpoch,
+	}, nil
+}
+
+// Enqueue persists job and sets its ID.
+func (q *MySQLJobQueue) Enqueue(ctx context.Context, job *QueuedJob) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var extractors []byte
+	if job.Extractors != nil {
+		var err error
+		if extractors, err = json.Marshal(job.Extractors); err != nil {
+			return fmt.Errorf("queue enqueue marshal: %w", err)
+		}
+	}
+
+	res, err := q.stmtEnqueue.ExecContext(ctx, job.FileID, job.FilePath, extractors)
+	if err != nil {
+		return fmt.Errorf("queue enqueue: %w", err)
+	}
+	if job.ID, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("queue enqueue id: %w", err)
+	}
+	return nil
+}
+
+// Claim marks one job with a fresh claim token, then reads it back by token.
+func (q *MySQLJobQueue) Claim(ctx context.Context) (*QueuedJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	token := uuid.New().String()
+	res, err := q.stmtClaim.ExecContext(ctx, q.instanceID, token, int64(q.lease/time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("queue claim: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, fmt.Errorf("queue claim: %w", sql.ErrNoRows)
+	}
+
+	job := &QueuedJob{Token: token}
+	var extractors []byte
+	if err := q.stmtClaimed.QueryRowContext(ctx, token).Scan(&job.ID, &job.FileID, &job.FilePath, &extractors); err != nil {
+		return nil, fmt.Errorf("queue claimed: %w", err)
+	}
+	if len(extractors) > 0 {
+		// Fall back to the default extractors if the stored JSON is corrupt.
+		_ = json.Unmarshal(extractors, &job.Extractors)
+	}
+
+	res, err = q.stmtEpoch.ExecContext(ctx, job.FileID)
+	if err != nil {
+		return nil, fmt.Errorf("queue epoch: %w", err)
+	}
+	// A deleted file matches no row and keeps epoch 0; its result is
+	// discarded as stale.
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		job.Epoch, _ = res.LastInsertId()
+	}
+	return job, nil
+}
+
+// Renew restarts the lease on a job claimed with token. The claim token
+// changes on every claim, so a match means no other instance has taken it.
+func (q *MySQLJobQueue) Renew(ctx context.Context, id int64, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	res, err := q.stmtRenew.ExecContext(ctx, id, token)
+	if err != nil {
+		return fmt.Errorf("queue renew: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+
+	// MySQL reports zero affected rows when claimed_at already holds the
+	// current second, so check the claim itself before giving it up.
+	var gotID int64
+	var fileID, filePath string
+	var extractors []byte
+	err = q.stmtClaimed.QueryRowContext(ctx, token).Scan(&gotID, &fileID, &filePath, &extractors)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && gotID != id) {
+		return fmt.Errorf("queue renew: %w", ErrClaimLost)
+	}
+	if err != nil {
+		return fmt.Errorf("queue renew check: %w", err)
+	}
+	return nil
+}
+
+// Complete removes a finished job from the queue.
+func (q *MySQLJobQueue) Complete(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if _, err := q.stmtComplete.ExecContext(ctx, id); err != nil {
+		return fmt.Errorf("queue complete: %w", err)
+	}
+	return nil
+}
+
+// Close releases all prepared statements.
+func (q *MySQLJobQueue) Close() error {
+	for _, s := range []*sql.Stmt{q.stmtEnqueue, q.stmtClaim, q.stmtClaimed, q.stmtRenew, q.stmtComplete, q.stmtEpoch} {
+		if s != nil {
+			s.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LeaderLock is a named, lease-based lock used for leader election.
+type LeaderLock interface {
+	// TryAcquire takes or renews the lock for holder until lease from now.
+	// It succeeds if the lock is free, expired or already held by holder,
+	// and reports whether holder owns the lock afterwards.
+	TryAcquire(ctx context.Context, name, holder string, lease time.Duration) (bool, error)
+
+	// Release gives up the lock if holder owns it.
+	Release(ctx context.Context, name, holder string) error
+}
+
+// MySQLLeaderLock implements LeaderLock on the leader_lock table. Lease
+// arithmetic uses the database clock, so instance clock skew does not matter.
+type MySQLLeaderLock struct {
+	stmtAcquire *sql.Stmt
+	stmtHolder  *sql.Stmt
+	stmtRelease *sql.Stmt
+}
+
+// NewMySQLLeaderLock prepares the lock statements.
+func NewMySQLLeaderLock(db *sql.DB) (*MySQLLeaderLock, error) {
+	// MySQL applies ON DUPLICATE KEY assignments left to right, so the
+	// expires_at condition sees holder as just updated: the lease is only
+	// extended when the caller now holds the lock.
+	stmtAcquire, err := db.Prepare(`INSERT INTO leader_lock (name, holder, expires_at)
+		VALUES (?, ?, UTC_TIMESTAMP(3) + INTERVAL ? MICROSECOND)
+		ON DUPLICATE KEY UPDATE
+			holder = IF(holder = VALUES(holder) OR expires_at < UTC_TIMESTAMP(3), VALUES(holder), holder),
+			expires_at = IF(holder = VALUES(holder), VALUES(expires_at), expires_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare leaderAcquire: %w", err)
+	}
+
+	stmtHolder, err := db.Prepare("SELECT holder FROM leader_lock WHERE name = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare leaderHolder: %w", err)
+	}
+
+	stmtRelease, err := db.Prepare("DELETE FROM leader_lock WHERE name = ? AND holder = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare leaderRelease: %w", err)
+	}
+
+	return &MySQLLeaderLock{
+		stmtAcquire: stmtAcquire,
+		stmtHolder:  stmtHolder,
+		stmtRelease: stmtRelease,
+	}, nil
+}
+
+// TryAcquire takes or renews the lock, then reads back the current holder.
+func (l *MySQLLeaderLock) TryAcquire(ctx context.Context, name, holder string, lease time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if _, err := l.stmtAcquire.ExecContext(ctx, name, holder, lease.Microseconds()); err != nil {
+		return false, fmt.Errorf("repo leaderAcquire: %w", err)
+	}
+
+	var current string
+	if err := l.stmtHolder.QueryRowContext(ctx, name).Scan(&current); err != nil {
+		return false, fmt.Errorf("repo leaderHolder: %w", err)
+	}
+	return current == holder, nil
+}
+
+// Release deletes the lock row if holder owns it.
+func (l *MySQLLeaderLock) Release(ctx context.Context, name, holder string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if _, err := l.stmtRelease.ExecContext(ctx, name, holder); err != nil {
+		return fmt.Errorf("repo leaderRelease: %w", err)
+	}
+	return nil
+}
+
+// Close releases all prepared statements.
+func (l *MySQLLeaderLock) Close() error {
+	for _, s := range []*sql.Stmt{l.stmtAcquire, l.stmtHolder, l.stmtRelease} {
+		if s != nil {
+			s.Close()
+		}
+	}
+	return nil
+}
@@ -4,29 +4,75 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 )
 
 const dbTimeout = 2 * time.Second
 
+// recordColumns is the column list scanned by scanRecord, in order.
+const recordColumns = "id, hash, size, status, file_path, created_at, metadata, owner, expires_at, original_name, mime_type, failure_reason, epoch, seq"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 // MySQLRepo implements Repository using prepared statements and context timeouts.
 type MySQLRepo struct {
-	db           *sql.DB
-	stmtCreate   *sql.Stmt
-	stmtGetByID  *sql.Stmt
-	stmtUpdStat  *sql.Stmt
-	stmtUpdMeta  *sql.Stmt
+	db          *sql.DB
+	stmtCreate  *sql.Stmt
+	stmtGetByID *sql.Stmt
+	stmtUpdStat *sql.Stmt
+	stmtUpdMeta *sql.Stmt
+	stmtUsage   *sql.Stmt
+	stmtDelete  *sql.Stmt
+	stmtUpsert  *sql.Stmt
+	stmtByHash  *sql.Stmt
+	stmtUpdPath *sql.Stmt
+	stmtDone    *sql.Stmt
+	stmtFailed  *sql.Stmt
+	stmtEpoch   *sql.Stmt
+	stmtMove    *sql.Stmt
+
+	// strictMeta and logger are set once by SetStrictMetadata before use.
+	strictMeta bool
+	logger     *slog.Logger
+
+	// policy is set once by SetContentPolicy before use.
+	policy ContentPolicy
+}
+
+// SetContentPolicy makes Create, Upsert, UpdateMetadata and
+// CompleteProcessing (and their transactional forms) reject records the policy forbids with
+// ErrPolicyViolation. Call it before the repo is shared.
+func (r *MySQLRepo) SetContentPolicy(p ContentPolicy) {
+	r.policy = p
+}
+
+// SetStrictMetadata controls reads of a metadata column that is not valid
+// JSON. Lenient (the default) returns the record with empty metadata; strict
+// fails the read with ErrCorruptMetadata. Strictness applies to the reads
+// that serve clients; recovery, expiry and purge scans stay lenient so one
+// corrupt row cannot stall them or keep itself from being removed. Either
+// way the corruption is logged to logger if it is non-nil. Call it before
+// the repo is shared.
+func (r *MySQLRepo) SetStrictMetadata(strict bool, logger *slog.Logger) {
+	r.strictMeta = strict
+	r.logger = logger
 }
 
 // NewMySQLRepo prepares all statements up front. The caller owns the *sql.DB lifetime.
 func NewMySQLRepo(db *sql.DB) (*MySQLRepo, error) {
-	stmtCreate, err := db.Prepare("INSERT INTO files (id, hash, size, status, file_path) VALUES (?, ?, ?, ?, ?)")
+	stmtCreate, err := db.Prepare("INSERT INTO files (id, hash, size, status, file_path, owner, expires_at, original_name) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return nil, fmt.Errorf("prepare create: %w", err)
 	}
 
-	stmtGetByID, err := db.Prepare("SELECT id, hash, size, status, file_path, created_at, metadata FROM files WHERE id = ?")
+	stmtGetByID, err := db.Prepare("SELECT " + recordColumns + " FROM files WHERE id = ?")
 	if err != nil {
 		return nil, fmt.Errorf("prepare getByID: %w", err)
 	}
@@ -36,53 +82,257 @@ func NewMySQLRepo(db *sql.DB) (*MySQLRepo, error) {
 		return nil, fmt.Errorf("prepare updateStatus: %w", err)
 	}
 
-	stmtUpdMeta, err := db.Prepare("UPDATE files SET hash = ?, size = ?, metadata = ? WHERE id = ?")
+	stmtUpdMeta, err := db.Prepare("UPDATE files SET hash = ?, size = ?, metadata = ?, mime_type = ? WHERE id = ?")
 	if err != nil {
 		return nil, fmt.Errorf("prepare updateMetadata: %w", err)
 	}
 
+	stmtUsage, err := db.Prepare("SELECT owner, COALESCE(SUM(size), 0) FROM files WHERE owner = ? GROUP BY owner")
+	if err != nil {
+		return nil, fmt.Errorf("prepare usageByOwner: %w", err)
+	}
+
+	stmtDelete, err := db.Prepare("DELETE FROM files WHERE id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare delete: %w", err)
+	}
+
+	// Only content fields are overwritten; ownership and lifecycle columns keep
+	// their original values so a replay cannot reassign or extend a file.
+	stmtUpsert, err := db.Prepare(`INSERT INTO files (id, hash, size, status, file_path, owner, expires_at, metadata, original_name, mime_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			hash = VALUES(hash),
+			size = VALUES(size),
+			status = VALUES(status),
+			file_path = VALUES(file_path),
+			metadata = VALUES(metadata),
+			mime_type = VALUES(mime_type),
+			epoch = epoch + 1`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare upsert: %w", err)
+	}
+
+	stmtByHash, err := db.Prepare("SELECT " + recordColumns + " FROM files WHERE hash = ? AND status = 'completed' AND (? = '' OR owner = ?) ORDER BY created_at DESC LIMIT 1")
+	if err != nil {
+		return nil, fmt.Errorf("prepare getByHash: %w", err)
+	}
+
+	stmtUpdPath, err := db.Prepare("UPDATE files SET file_path = ? WHERE id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare updateFilePath: %w", err)
+	}
+
+	stmtDone, err := db.Prepare("UPDATE files SET hash = ?, size = ?, metadata = ?, mime_type = ?, status = ?, failure_reason = '' WHERE id = ? AND epoch = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare completeProcessing: %w", err)
+	}
+
+	stmtFailed, err := db.Prepare("UPDATE files SET status = ?, failure_reason = ? WHERE id = ? AND epoch = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare markFailed: %w", err)
+	}
+
+	// LAST_INSERT_ID(expr) hands the new epoch back on the same connection,
+	// so concurrent bumps cannot read each other's value.
+	stmtEpoch, err := db.Prepare("UPDATE files SET epoch = LAST_INSERT_ID(epoch + 1) WHERE id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare beginProcessing: %w", err)
+	}
+
+	stmtMove, err := db.Prepare("UPDATE files SET status = ?, epoch = LAST_INSERT_ID(epoch + 1) WHERE id = ? AND status = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare transitionStatus: %w", err)
+	}
+
 	return &MySQLRepo{
 		db:          db,
 		stmtCreate:  stmtCreate,
 		stmtGetByID: stmtGetByID,
 		stmtUpdStat: stmtUpdStat,
 		stmtUpdMeta: stmtUpdMeta,
+		stmtUsage:   stmtUsage,
+		stmtDelete:  stmtDelete,
+		stmtUpsert:  stmtUpsert,
+		stmtByHash:  stmtByHash,
+		stmtUpdPath: stmtUpdPath,
+		stmtDone:    stmtDone,
+		stmtFailed:  stmtFailed,
+		stmtEpoch:   stmtEpoch,
+		stmtMove:    stmtMove,
 	}, nil
 }
 
+// Ping checks database connectivity.
+func (r *MySQLRepo) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if err := r.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("repo ping: %w", err)
+	}
+	return nil
+}
+
 // Create inserts a new file record.
 func (r *MySQLRepo) Create(ctx context.Context, rec *FileRecord) error {
 	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	_, err := r.stmtCreate.ExecContext(ctx, rec.ID, rec.Hash, rec.Size, rec.Status, rec.FilePath)
+	if err := r.policy.check(rec.Size, ""); err != nil {
+		return fmt.Errorf("repo create: %w", err)
+	}
+	_, err := r.stmtCreate.ExecContext(ctx, rec.ID, rec.Hash, rec.Size, rec.Status, rec.FilePath, rec.Owner, nullTime(rec.ExpiresAt), rec.OriginalName)
 	if err != nil {
 		return fmt.Errorf("repo create: %w", err)
 	}
 	return nil
 }
 
+// CreateWithMetadata runs the create and updateMetadata statements in a single
+// transaction so an imported record is never visible half-populated.
+func (r *MySQLRepo) CreateWithMetadata(ctx context.Context, rec *FileRecord) error {
+	err := r.WithTx(ctx, func(tx RepositoryTx) error {
+		if err := tx.Create(ctx, rec); err != nil {
+			return err
+		}
+		return tx.UpdateMetadata(ctx, rec.ID, rec.Hash, rec.Size, rec.Metadata)
+	})
+	if err != nil {
+		return fmt.Errorf("repo createWithMetadata: %w", err)
+	}
+	return nil
+}
+
+// Upsert creates the record or overwrites its content fields if it exists.
+func (r *MySQLRepo) Upsert(ctx context.Context, rec *FileRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if err := r.policy.check(rec.Size, metaMimeType(rec.Metadata)); err != nil {
+		return fmt.Errorf("repo upsert: %w", err)
+	}
+	metaJSON, err := json.Marshal(rec.Metadata)
+	if err != nil {
+		return fmt.Errorf("repo upsert marshal: %w", err)
+	}
+
+	_, err = r.stmtUpsert.ExecContext(ctx, rec.ID, rec.Hash, rec.Size, rec.Status, rec.FilePath, rec.Owner, nullTime(rec.ExpiresAt), metaJSON, rec.OriginalName, metaMimeType(rec.Metadata))
+	if err != nil {
+		return fmt.Errorf("repo upsert: %w", err)
+	}
+	return nil
+}
+
 // GetByID retrieves a file record by UUID.
 func (r *MySQLRepo) GetByID(ctx context.Context, id string) (*FileRecord, error) {
 	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	rec := &FileRecord{}
-	var metaJSON []byte
-	err := r.stmtGetByID.QueryRowContext(ctx, id).Scan(
-		&rec.ID, &rec.Hash, &rec.Size, &rec.Status, &rec.FilePath, &rec.CreatedAt, &metaJSON,
-	)
+	rec, err := r.scanRecord(r.stmtGetByID.QueryRowContext(ctx, id), r.strictMeta)
 	if err != nil {
 		return nil, fmt.Errorf("repo getByID: %w", err)
 	}
+	return rec, nil
+}
+
+// metaMimeType extracts the value denormalized into the mime_type column:
+// the lower-cased media type without parameters, so "text/plain" matches
+// "text/plain; charset=utf-8".
+func metaMimeType(meta map[string]interface{}) string {
+	mt, _ := meta["mime_type"].(string)
+	mt, _, _ = strings.Cut(mt, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// nullTime maps the zero time to SQL NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// GetByHash retrieves the newest completed record with the given hash that
+// belongs to owner, or to any owner when owner is empty.
+func (r *MySQLRepo) GetByHash(ctx context.Context, owner, hash string) (*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rec, err := r.scanRecord(r.stmtByHash.QueryRowContext(ctx, hash, owner, owner), r.strictMeta)
+	if err != nil {
+		return nil, fmt.Errorf("repo getByHash: %w", err)
+	}
+	return rec, nil
+}
+
+// GetByIDs retrieves the records for ids with a single IN query. Duplicate IDs
+// are collapsed; more than MaxBatchIDs distinct IDs returns ErrBatchTooLarge.
+func (r *MySQLRepo) GetByIDs(ctx context.Context, ids []string) (map[string]*FileRecord, error) {
+	seen := make(map[string]struct{}, len(ids))
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		args = append(args, id)
+	}
+	if len(args) == 0 {
+		return map[string]*FileRecord{}, nil
+	}
+	if len(args) > MaxBatchIDs {
+		return nil, fmt.Errorf("repo getByIDs: %d ids: %w", len(args), ErrBatchTooLarge)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	// Only "?" placeholders are interpolated; IDs travel as bound arguments.
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	rows, err := r.db.QueryContext(ctx, "SELECT "+recordColumns+" FROM files WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo getByIDs: %w", err)
+	}
+	records, err := r.scanRecords(ctx, rows, "getByIDs", r.strictMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*FileRecord, len(records))
+	for _, rec := range records {
+		out[rec.ID] = rec
+	}
+	return out, nil
+}
+
+// scanRecord reads one row selected with recordColumns. strict fails the
+// read on corrupt metadata instead of returning the record without it.
+func (r *MySQLRepo) scanRecord(row rowScanner, strict bool) (*FileRecord, error) {
+	rec := &FileRecord{}
+	var (
+		metaJSON  []byte
+		expiresAt sql.NullTime
+	)
+	if err := row.Scan(&rec.ID, &rec.Hash, &rec.Size, &rec.Status, &rec.FilePath, &rec.CreatedAt, &metaJSON, &rec.Owner, &expiresAt, &rec.OriginalName, &rec.MimeType, &rec.FailureReason, &rec.Epoch, &rec.Seq); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		rec.ExpiresAt = expiresAt.Time
+	}
 
 	if len(metaJSON) > 0 {
 		if err := json.Unmarshal(metaJSON, &rec.Metadata); err != nil {
-			// Log error but don't fail the request? Or just ignore.
-			// For now, let's just proceed with empty metadata if corrupt.
+			if r.logger != nil {
+				r.logger.Warn("corrupt metadata",
+					slog.String("file_id", rec.ID),
+					slog.Bool("strict", strict),
+					slog.String("error", err.Error()),
+				)
+			}
+			if strict {
+				return nil, fmt.Errorf("file %s: %w: %v", rec.ID, ErrCorruptMetadata, err)
+			}
+			rec.Metadata = nil
 		}
 	}
-
 	return rec, nil
 }
 
@@ -103,47 +353,489 @@ func (r *MySQLRepo) UpdateMetadata(ctx context.Context, id, hash string, size in
 	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
+	if err := r.policy.check(size, metaMimeType(meta)); err != nil {
+		return fmt.Errorf("repo updateMetadata: %w", err)
+	}
 	metaJSON, err := json.Marshal(meta)
 	if err != nil {
 		return fmt.Errorf("repo updateMetadata marshal: %w", err)
 	}
 
-	_, err = r.stmtUpdMeta.ExecContext(ctx, hash, size, metaJSON, id)
+	_, err = r.stmtUpdMeta.ExecContext(ctx, hash, size, metaJSON, metaMimeType(meta), id)
 	if err != nil {
 		return fmt.Errorf("repo updateMetadata: %w", err)
 	}
 	return nil
 }
 
+// CompleteProcessing stores the processing outcome and marks the file
+// completed in one statement.
+func (r *MySQLRepo) CompleteProcessing(ctx context.Context, id string, epoch int64, hash string, size int64, meta map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if err := r.policy.check(size, metaMimeType(meta)); err != nil {
+		return fmt.Errorf("repo completeProcessing: %w", err)
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("repo completeProcessing marshal: %w", err)
+	}
+
+	res, err := r.stmtDone.ExecContext(ctx, hash, size, metaJSON, metaMimeType(meta), StatusCompleted, id, epoch)
+	if err != nil {
+		return fmt.Errorf("repo completeProcessing: %w", err)
+	}
+	return checkEpoch(ctx, r.db, res, id, epoch, "completeProcessing")
+}
+
+// MarkFailed sets the status to failed and records the reason.
+func (r *MySQLRepo) MarkFailed(ctx context.Context, id string, epoch int64, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	res, err := r.stmtFailed.ExecContext(ctx, StatusFailed, TruncateReason(reason, MaxFailureReasonLen), id, epoch)
+	if err != nil {
+		return fmt.Errorf("repo markFailed: %w", err)
+	}
+	return checkEpoch(ctx, r.db, res, id, epoch, "markFailed")
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// checkEpoch maps an epoch-guarded update that matched no row to
+// ErrStaleEpoch. MySQL reports changed rows, not matched rows, so an update
+// that rewrote the values already stored (a repeated MarkFailed with the same
+// reason) also reports zero; in that case the epoch is read back through q
+// and the update counts as applied if the row still carries it.
+func checkEpoch(ctx context.Context, q rowQuerier, res sql.Result, id string, epoch int64, op string) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return nil
+	}
+	var current int64
+	switch err := q.QueryRowContext(ctx, "SELECT epoch FROM files WHERE id = ?", id).Scan(&current); {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("repo %s: %w", op, ErrStaleEpoch)
+	case err != nil:
+		return fmt.Errorf("repo %s epoch: %w", op, err)
+	case current != epoch:
+		return fmt.Errorf("repo %s: %w", op, ErrStaleEpoch)
+	}
+	return nil
+}
+
+// BeginProcessing increments the record's epoch and returns the new value.
+func (r *MySQLRepo) BeginProcessing(ctx context.Context, id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	res, err := r.stmtEpoch.ExecContext(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("repo beginProcessing: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, fmt.Errorf("repo beginProcessing: %w", sql.ErrNoRows)
+	}
+	epoch, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("repo beginProcessing: %w", err)
+	}
+	return epoch, nil
+}
+
+// TransitionStatus moves a file from status from to status to and bumps its
+// epoch, returning the new value.
+func (r *MySQLRepo) TransitionStatus(ctx context.Context, id, from, to string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	res, err := r.stmtMove.ExecContext(ctx, to, id, from)
+	if err != nil {
+		return 0, fmt.Errorf("repo transitionStatus: %w", err)
+	}
+	// The epoch always changes, so a matched row is always reported.
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return 0, fmt.Errorf("repo transitionStatus: %w", ErrStatusChanged)
+	}
+	epoch, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("repo transitionStatus: %w", err)
+	}
+	return epoch, nil
+}
+
+// UpdateFilePath points a record at a new on-disk location.
+func (r *MySQLRepo) UpdateFilePath(ctx context.Context, id, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if _, err := r.stmtUpdPath.ExecContext(ctx, path, id); err != nil {
+		return fmt.Errorf("repo updateFilePath: %w", err)
+	}
+	return nil
+}
+
 // ListAll retrieves all file records ordered by most recent first.
 func (r *MySQLRepo) ListAll(ctx context.Context) ([]*FileRecord, error) {
 	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	rows, err := r.db.QueryContext(ctx, "SELECT id, hash, size, status, file_path, created_at, metadata FROM files ORDER BY id DESC LIMIT 100")
+	rows, err := r.db.QueryContext(ctx, "SELECT "+recordColumns+" FROM files ORDER BY id DESC LIMIT 100")
 	if err != nil {
 		return nil, fmt.Errorf("repo listAll: %w", err)
 	}
+	return r.scanRecords(ctx, rows, "listAll", r.strictMeta)
+}
+
+// ListByOwner retrieves the records owned by owner, most recent first.
+func (r *MySQLRepo) ListByOwner(ctx context.Context, owner string) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+recordColumns+" FROM files WHERE owner = ? ORDER BY id DESC LIMIT 100", owner)
+	if err != nil {
+		return nil, fmt.Errorf("repo listByOwner: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "listByOwner", r.strictMeta)
+}
+
+// ListByMimeType retrieves records matching mimeType via the mime_type index.
+// "type/*" becomes a prefix match, which the index still serves.
+func (r *MySQLRepo) ListByMimeType(ctx context.Context, owner, mimeType string) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := "SELECT " + recordColumns + " FROM files WHERE mime_type = ?"
+	arg := mimeType
+	if top, ok := strings.CutSuffix(mimeType, "/*"); ok {
+		query = "SELECT " + recordColumns + " FROM files WHERE mime_type LIKE ?"
+		arg = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(top) + "/%"
+	}
+	args := []any{arg}
+	if owner != "" {
+		query += " AND owner = ?"
+		args = append(args, owner)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY id DESC LIMIT 100", args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo listByMimeType: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "listByMimeType", r.strictMeta)
+}
+
+// scanRecords drains rows into FileRecords and closes them. op names the
+// calling method in wrapped errors; strict is passed to scanRecord.
+// Iteration stops as soon as ctx is cancelled so a disconnected client does
+// not keep a large scan running.
+func (r *MySQLRepo) scanRecords(ctx context.Context, rows *sql.Rows, op string, strict bool) ([]*FileRecord, error) {
 	defer rows.Close()
 
 	var records []*FileRecord
 	for rows.Next() {
-		rec := &FileRecord{}
-		var metaJSON []byte
-		if err := rows.Scan(&rec.ID, &rec.Hash, &rec.Size, &rec.Status, &rec.FilePath, &rec.CreatedAt, &metaJSON); err != nil {
-			return nil, fmt.Errorf("repo listAll scan: %w", err)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("repo %s: %w", op, err)
 		}
-		if len(metaJSON) > 0 {
-			_ = json.Unmarshal(metaJSON, &rec.Metadata)
+		rec, err := r.scanRecord(rows, strict)
+		if err != nil {
+			return nil, fmt.Errorf("repo %s scan: %w", op, err)
 		}
 		records = append(records, rec)
 	}
 	return records, rows.Err()
 }
 
+// ListByStatus pages through records with the given status by ID.
+func (r *MySQLRepo) ListByStatus(ctx context.Context, status, afterID string, limit int) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+recordColumns+" FROM files WHERE status = ? AND id > ? ORDER BY id LIMIT ?", status, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("repo listByStatus: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "listByStatus", false)
+}
+
+// ListByDateRange filters on the created_at index, then owner and status.
+func (r *MySQLRepo) ListByDateRange(ctx context.Context, owner, status string, from, to time.Time, limit, offset int) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := "SELECT " + recordColumns + " FROM files WHERE created_at BETWEEN ? AND ?"
+	args := []any{from, to}
+	if owner != "" {
+		query += " AND owner = ?"
+		args = append(args, owner)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo listByDateRange: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "listByDateRange", r.strictMeta)
+}
+
+// ReindexColumns reads one page of metadata and rewrites stale columns in a
+// single transaction. The page is read FOR UPDATE, so processing that
+// completes meanwhile waits for the commit instead of having its fresh
+// columns overwritten from the metadata read here.
+func (r *MySQLRepo) ReindexColumns(ctx context.Context, afterID string, limit int) (ReindexBatch, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var batch ReindexBatch
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return batch, fmt.Errorf("repo reindexColumns begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, metadata, mime_type FROM files WHERE id > ? ORDER BY id LIMIT ? FOR UPDATE", afterID, limit)
+	if err != nil {
+		return batch, fmt.Errorf("repo reindexColumns: %w", err)
+	}
+	type change struct{ id, mimeType string }
+	var changes []change
+	for rows.Next() {
+		var (
+			id, stored string
+			metaJSON   []byte
+		)
+		if err := rows.Scan(&id, &metaJSON, &stored); err != nil {
+			rows.Close()
+			return batch, fmt.Errorf("repo reindexColumns scan: %w", err)
+		}
+		batch.Scanned++
+		batch.LastID = id
+
+		var meta map[string]interface{}
+		if len(metaJSON) > 0 {
+			if err := json.Unmarshal(metaJSON, &meta); err != nil {
+				batch.Corrupt++
+				continue
+			}
+		}
+		if mt := metaMimeType(meta); mt != stored {
+			changes = append(changes, change{id, mt})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return batch, fmt.Errorf("repo reindexColumns: %w", err)
+	}
+
+	for _, c := range changes {
+		if _, err := tx.ExecContext(ctx, "UPDATE files SET mime_type = ? WHERE id = ?", c.mimeType, c.id); err != nil {
+			return batch, fmt.Errorf("repo reindexColumns update: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return batch, fmt.Errorf("repo reindexColumns commit: %w", err)
+	}
+	batch.Updated = len(changes)
+	return batch, nil
+}
+
+// iteratePage is the number of rows Iterate loads per query.
+const iteratePage = 500
+
+// Iterate walks the catalog by keyset pagination. Each page is its own
+// query under dbTimeout, so no connection is held while fn runs.
+func (r *MySQLRepo) Iterate(ctx context.Context, owner string, fn func(*FileRecord) error) error {
+	afterID := ""
+	for {
+		page, err := r.iteratePage(ctx, owner, afterID)
+		if err != nil {
+			return err
+		}
+		for _, rec := range page {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if len(page) < iteratePage {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+func (r *MySQLRepo) iteratePage(ctx context.Context, owner, afterID string) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := "SELECT " + recordColumns + " FROM files WHERE id > ?"
+	args := []any{afterID}
+	if owner != "" {
+		query += " AND owner = ?"
+		args = append(args, owner)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, iteratePage)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo iterate: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "iterate", r.strictMeta)
+}
+
+// ListExpired retrieves up to limit records whose expiry is at or before now.
+func (r *MySQLRepo) ListExpired(ctx context.Context, now time.Time, limit int) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+recordColumns+" FROM files WHERE expires_at IS NOT NULL AND expires_at <= ? ORDER BY expires_at LIMIT ?", now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("repo listExpired: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "listExpired", false)
+}
+
+// ListCreatedBefore pages through records created before cutoff by ID.
+func (r *MySQLRepo) ListCreatedBefore(ctx context.Context, cutoff time.Time, afterID string, limit int) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+recordColumns+" FROM files WHERE created_at < ? AND id > ? ORDER BY id LIMIT ?", cutoff, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("repo listCreatedBefore: %w", err)
+	}
+	return r.scanRecords(ctx, rows, "listCreatedBefore", false)
+}
+
+// PurgeOlderThan locks and deletes the oldest PurgeBatch records created
+// before cutoff in one transaction.
+func (r *MySQLRepo) PurgeOlderThan(ctx context.Context, cutoff time.Time) ([]*FileRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("repo purgeOlderThan begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+recordColumns+" FROM files WHERE created_at < ? ORDER BY created_at LIMIT ? FOR UPDATE", cutoff, PurgeBatch)
+	if err != nil {
+		return nil, fmt.Errorf("repo purgeOlderThan: %w", err)
+	}
+	records, err := r.scanRecords(ctx, rows, "purgeOlderThan", false)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	args := make([]any, len(records))
+	for i, rec := range records {
+		args[i] = rec.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	if _, err := tx.ExecContext(ctx, "DELETE FROM files WHERE id IN ("+placeholders+")", args...); err != nil {
+		return nil, fmt.Errorf("repo purgeOlderThan delete: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("repo purgeOlderThan commit: %w", err)
+	}
+	return records, nil
+}
+
+// Delete removes a file record by UUID.
+func (r *MySQLRepo) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	res, err := r.stmtDelete.ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("repo delete: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repo delete: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// IngestStats groups recent uploads by minute in SQL. Minutes are counted
+// from the window start, so the grouping does not depend on time zones.
+func (r *MySQLRepo) IngestStats(ctx context.Context, owner string, window time.Duration) ([]IngestBucket, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	now := time.Now()
+	since := now.Add(-window).Truncate(time.Minute)
+	buckets := make([]IngestBucket, int(now.Sub(since)/time.Minute)+1)
+	for i := range buckets {
+		buckets[i].Start = since.Add(time.Duration(i) * time.Minute).UTC()
+	}
+
+	query := "SELECT TIMESTAMPDIFF(MINUTE, ?, created_at) AS minute, COUNT(*), COALESCE(SUM(size), 0), " +
+		"COALESCE(SUM(status = ?), 0), COALESCE(SUM(status = ?), 0) FROM files WHERE created_at >= ?"
+	args := []any{since, StatusCompleted, StatusFailed, since}
+	if owner != "" {
+		query += " AND owner = ?"
+		args = append(args, owner)
+	}
+	query += " GROUP BY minute ORDER BY minute"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repo ingestStats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			minute int64
+			b      IngestBucket
+		)
+		if err := rows.Scan(&minute, &b.Uploads, &b.Bytes, &b.Completed, &b.Failed); err != nil {
+			return nil, fmt.Errorf("repo ingestStats scan: %w", err)
+		}
+		if minute < 0 || minute >= int64(len(buckets)) {
+			continue // created after now, e.g. clock skew between hosts
+		}
+		b.Start = buckets[minute].Start
+		buckets[minute] = b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repo ingestStats: %w", err)
+	}
+	return buckets, nil
+}
+
+// UsageByOwner sums the stored size of all files belonging to owner.
+// An owner with no files has zero usage.
+func (r *MySQLRepo) UsageByOwner(ctx context.Context, owner string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var (
+		gotOwner string
+		used     int64
+	)
+	err := r.stmtUsage.QueryRowContext(ctx, owner).Scan(&gotOwner, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("repo usageByOwner: %w", err)
+	}
+	return used, nil
+}
+
 // Close releases all prepared statements.
 func (r *MySQLRepo) Close() error {
-	for _, s := range []*sql.Stmt{r.stmtCreate, r.stmtGetByID, r.stmtUpdStat, r.stmtUpdMeta} {
+	for _, s := range []*sql.Stmt{r.stmtCreate, r.stmtGetByID, r.stmtUpdStat, r.stmtUpdMeta, r.stmtUsage, r.stmtDelete, r.stmtUpsert, r.stmtByHash, r.stmtUpdPath, r.stmtDone, r.stmtFailed, r.stmtEpoch, r.stmtMove} {
 		if s != nil {
 			s.Close()
 		}
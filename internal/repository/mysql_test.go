@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRows serves n identical file rows and calls onNext before each one.
+type fakeRows struct {
+	n, served int
+	onNext    func(served int)
+}
+
+func (r *fakeRows) Columns() []string { return strings.Split(recordColumns, ", ") }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.served == r.n {
+		return io.EOF
+	}
+	r.onNext(r.served)
+	r.served++
+	values := []driver.Value{"id", "", int64(1), StatusPending, "/tmp/f", time.Now(), []byte("{}"), DefaultOwner, nil, "f", "", "", int64(0), int64(r.served)}
+	copy(dest, values)
+	return nil
+}
+
+// fakeConn answers every query with rows; nothing else is supported.
+type fakeConn struct{ rows *fakeRows }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{c.rows}, nil }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ rows *fakeRows }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) { return s.rows, nil }
+
+type fakeConnector struct{ conn *fakeConn }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+func TestScanRecordsStopsWhenCancelledMidIteration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The client goes away while the second row is being read.
+	rows := &fakeRows{n: 1000, onNext: func(served int) {
+		if served == 1 {
+			cancel()
+		}
+	}}
+	db := sql.OpenDB(fakeConnector{&fakeConn{rows}})
+	defer db.Close()
+
+	// Query on its own context so only scanRecords observes the cancel.
+	sqlRows, err := db.QueryContext(context.Background(), "SELECT "+recordColumns+" FROM files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &MySQLRepo{}
+	records, err := r.scanRecords(ctx, sqlRows, "listAll", false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if records != nil {
+		t.Errorf("got %d records, want none", len(records))
+	}
+	if rows.served > 2 {
+		t.Errorf("read %d rows after cancellation, want the scan to stop at once", rows.served)
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPolicyViolation is returned when a write would store a record that the
+// configured ContentPolicy forbids.
+var ErrPolicyViolation = errors.New("repository: content policy violation")
+
+// ContentPolicy limits what MySQLRepo stores, so REST uploads and gRPC
+// imports are held to the same rules. The zero value allows everything.
+type ContentPolicy struct {
+	// MaxSize caps a record's size in bytes; 0 means unlimited.
+	MaxSize int64
+
+	// AllowedMIMETypes lists accepted MIME types, either exact
+	// ("image/png") or by top-level type ("image/*"). Empty allows any.
+	AllowedMIMETypes []string
+}
+
+// ParseMIMEList splits a comma-separated list such as "image/*,text/plain"
+// into normalized entries, dropping blanks.
+func ParseMIMEList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if mt := strings.ToLower(strings.TrimSpace(part)); mt != "" {
+			out = append(out, mt)
+		}
+	}
+	return out
+}
+
+// check reports whether a record of the given size and MIME type may be
+// stored. An empty mime is not checked, since records are created before
+// their content has been sniffed.
+func (p ContentPolicy) check(size int64, mime string) error {
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return fmt.Errorf("%w: size %d exceeds limit of %d bytes", ErrPolicyViolation, size, p.MaxSize)
+	}
+	if mime == "" || len(p.AllowedMIMETypes) == 0 {
+		return nil
+	}
+	for _, allowed := range p.AllowedMIMETypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if major, _, _ := strings.Cut(mime, "/"); major == prefix {
+				return nil
+			}
+		} else if mime == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: mime type %q is not allowed", ErrPolicyViolation, mime)
+}
@@ -2,35 +2,218 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// MaxBatchIDs caps how many IDs GetByIDs accepts in one call, keeping the
+// generated IN (...) list well under MySQL's placeholder and packet limits.
+const MaxBatchIDs = 500
+
+// PurgeBatch caps how many records one PurgeOlderThan call deletes, so row
+// locks are held only briefly.
+const PurgeBatch = 500
+
+// ErrStaleEpoch is returned when a processing result is applied to a record
+// whose epoch has moved on: it was reprocessed, replaced or deleted since the
+// job started.
+var ErrStaleEpoch = errors.New("repository: record epoch changed since processing started")
+
+// ErrStatusChanged is returned by TransitionStatus when the file is no longer
+// in the status the caller read.
+var ErrStatusChanged = errors.New("repository: file status changed concurrently")
+
+// ErrClaimLost is returned by JobQueue.Renew when the job's claim lease
+// lapsed and another claim has taken it, or the job was completed.
+var ErrClaimLost = errors.New("repository: job claim lost")
+
+// ErrCorruptMetadata is returned by reads when strict metadata checking is
+// enabled and a record's metadata column is not valid JSON.
+var ErrCorruptMetadata = errors.New("repository: corrupt metadata")
+
+// ErrBatchTooLarge is returned when a batch lookup exceeds MaxBatchIDs.
+var ErrBatchTooLarge = errors.New("repository: batch exceeds MaxBatchIDs")
+
+// DefaultOwner is recorded for files uploaded without a client identity.
+const DefaultOwner = "shared"
+
 // FileRecord represents a persisted file entry.
 type FileRecord struct {
-	ID        string
-	Hash      string
-	Size      int64
-	Status    string
-	FilePath  string
-	CreatedAt time.Time
-	Metadata  map[string]interface{} // Flexible JSON storage
+	ID            string
+	Hash          string
+	Size          int64
+	Status        string
+	FilePath      string
+	CreatedAt     time.Time
+	Metadata      map[string]interface{} // Flexible JSON storage
+	Owner         string                 // client identity that uploaded the file
+	ExpiresAt     time.Time              // zero means the file never expires
+	OriginalName  string                 // client-supplied filename, empty if unknown
+	MimeType      string                 // Metadata["mime_type"] without parameters, for indexed filtering
+	FailureReason string                 // why processing last failed; cleared on completion
+	Epoch         int64                  // bumped each time processing (re)starts; see BeginProcessing
+	Seq           uint64                 // unique, increasing (gaps allowed) number assigned at insert
+}
+
+// IngestBucket summarizes the uploads created during one minute. Completed
+// and Failed count those uploads by their current status.
+type IngestBucket struct {
+	Start     time.Time `json:"start"`
+	Uploads   int64     `json:"uploads"`
+	Bytes     int64     `json:"bytes"`
+	Completed int64     `json:"completed"`
+	Failed    int64     `json:"failed"`
+}
+
+// ReindexBatch reports one ReindexColumns call.
+type ReindexBatch struct {
+	LastID  string // resume cursor; "" when the batch was empty
+	Scanned int
+	Updated int // rows whose indexed columns were out of date
+	Corrupt int // rows skipped because their metadata is not valid JSON
+}
+
+// RepositoryTx exposes the mutating Repository methods bound to a single
+// transaction. It is only valid inside the WithTx callback that received it.
+type RepositoryTx interface {
+	Create(ctx context.Context, record *FileRecord) error
+	Upsert(ctx context.Context, record *FileRecord) error
+	Delete(ctx context.Context, id string) error
+	UpdateStatus(ctx context.Context, id, status string) error
+	UpdateMetadata(ctx context.Context, id, hash string, size int64, meta map[string]interface{}) error
+	UpdateFilePath(ctx context.Context, id, path string) error
+	CompleteProcessing(ctx context.Context, id string, epoch int64, hash string, size int64, meta map[string]interface{}) error
+	MarkFailed(ctx context.Context, id string, epoch int64, reason string) error
 }
 
 // Repository is a small, focused interface for file metadata persistence.
 // Implementations must honour the supplied context for cancellation and timeouts.
 type Repository interface {
+	// Ping verifies the backing store is reachable.
+	Ping(ctx context.Context) error
+
 	// Create inserts a new file record.
 	Create(ctx context.Context, record *FileRecord) error
 
+	// CreateWithMetadata inserts a record together with its hash, size, and
+	// metadata atomically.
+	CreateWithMetadata(ctx context.Context, record *FileRecord) error
+
+	// Upsert inserts a record, or overwrites hash, size, status, file_path and
+	// metadata if the ID already exists. Owner, created_at and expires_at of
+	// an existing record are preserved.
+	Upsert(ctx context.Context, record *FileRecord) error
+
 	// GetByID retrieves a file record by its UUID.
 	GetByID(ctx context.Context, id string) (*FileRecord, error)
 
+	// GetByHash retrieves the most recent completed record of owner with the
+	// given SHA-256 hash. An empty owner matches every owner. Returns
+	// sql.ErrNoRows if none exists.
+	GetByHash(ctx context.Context, owner, hash string) (*FileRecord, error)
+
+	// GetByIDs retrieves up to MaxBatchIDs records in one query, keyed by ID.
+	// IDs with no record are simply absent from the map.
+	GetByIDs(ctx context.Context, ids []string) (map[string]*FileRecord, error)
+
 	// ListAll retrieves all file records (for dashboard display).
 	ListAll(ctx context.Context) ([]*FileRecord, error)
 
+	// ListByOwner retrieves the file records belonging to owner.
+	ListByOwner(ctx context.Context, owner string) ([]*FileRecord, error)
+
+	// ListByMimeType retrieves records whose MIME type matches mimeType,
+	// most recent first. A "type/*" pattern matches the whole top-level type.
+	// An empty owner matches every owner.
+	ListByMimeType(ctx context.Context, owner, mimeType string) ([]*FileRecord, error)
+
+	// ListByStatus retrieves up to limit records with the given status,
+	// ordered by ID. Pass the last ID of the previous page as afterID to
+	// continue ("" starts from the beginning).
+	ListByStatus(ctx context.Context, status, afterID string, limit int) ([]*FileRecord, error)
+
+	// ListByDateRange retrieves up to limit records created between from
+	// and to inclusive, newest first, skipping the first offset. An empty
+	// owner or status matches any.
+	ListByDateRange(ctx context.Context, owner, status string, from, to time.Time, limit, offset int) ([]*FileRecord, error)
+
+	// ReindexColumns recomputes the columns denormalized from metadata
+	// (mime_type) for up to limit records after afterID in ID order. Only
+	// rows whose stored value differs are written, so reruns are harmless.
+	ReindexColumns(ctx context.Context, afterID string, limit int) (ReindexBatch, error)
+
+	// Iterate calls fn for every record of owner ("" for all owners) in ID
+	// order, loading one page at a time so memory stays flat however large
+	// the catalog. It stops at the first error from fn or the store.
+	Iterate(ctx context.Context, owner string, fn func(*FileRecord) error) error
+
+	// ListExpired retrieves up to limit records whose expiry is at or before now.
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]*FileRecord, error)
+
+	// ListCreatedBefore retrieves up to limit records created before cutoff
+	// with IDs after afterID, in ID order. It previews what PurgeOlderThan
+	// would delete without locking anything.
+	ListCreatedBefore(ctx context.Context, cutoff time.Time, afterID string, limit int) ([]*FileRecord, error)
+
+	// PurgeOlderThan deletes up to PurgeBatch records created before cutoff
+	// and returns them, so the caller can remove their files from disk. Each
+	// call is one short transaction; call again until it returns none.
+	PurgeOlderThan(ctx context.Context, cutoff time.Time) ([]*FileRecord, error)
+
+	// Delete removes a file record. Returns sql.ErrNoRows if it does not exist.
+	Delete(ctx context.Context, id string) error
+
 	// UpdateStatus sets the processing status for a file.
 	UpdateStatus(ctx context.Context, id, status string) error
 
-	// UpdateMetadata sets the computed hash, size, and rich metadata.
+	// UpdateMetadata sets the computed hash, size, and rich metadata. The
+	// mime_type column is kept in sync with meta["mime_type"].
 	UpdateMetadata(ctx context.Context, id, hash string, size int64, meta map[string]interface{}) error
+
+	// UpdateFilePath points a record at a new on-disk location.
+	UpdateFilePath(ctx context.Context, id, path string) error
+
+	// BeginProcessing bumps the record's epoch and returns the new value.
+	// Call it before (re)submitting a job for an existing record, so any
+	// result still in flight from an earlier job is rejected as stale.
+	BeginProcessing(ctx context.Context, id string) (int64, error)
+
+	// TransitionStatus moves a file from status from to status to only if it
+	// is still in from, and bumps its epoch like BeginProcessing so a result
+	// owed by processing already under way is discarded as stale. It returns
+	// the new epoch, or ErrStatusChanged if the status was no longer from.
+	TransitionStatus(ctx context.Context, id, from, to string) (int64, error)
+
+	// CompleteProcessing stores the hash, size and metadata and marks the
+	// file completed in a single statement, so a crash can never leave
+	// metadata written on a record that is still pending. Any earlier
+	// failure reason is cleared. Returns ErrStaleEpoch, writing nothing, if
+	// the record's epoch is no longer epoch.
+	CompleteProcessing(ctx context.Context, id string, epoch int64, hash string, size int64, meta map[string]interface{}) error
+
+	// MarkFailed sets the status to failed and records why. Reasons longer
+	// than MaxFailureReasonLen are truncated. Like CompleteProcessing it
+	// only applies while the record is at epoch.
+	MarkFailed(ctx context.Context, id string, epoch int64, reason string) error
+
+	// UsageByOwner returns the total bytes stored by the given owner.
+	UsageByOwner(ctx context.Context, owner string) (int64, error)
+
+	// IngestStats returns one IngestBucket per minute covering the last
+	// window, oldest first, including minutes without uploads. An empty
+	// owner covers every owner.
+	IngestStats(ctx context.Context, owner string, window time.Duration) ([]IngestBucket, error)
+
+	// ReplaceMetadataItems stores list metadata moved out of the metadata
+	// column (see SplitListMetadata). Items are deleted with their file.
+	ReplaceMetadataItems(ctx context.Context, fileID string, lists map[string][]interface{}) error
+
+	// ListMetadataItems pages through a file's stored items, optionally for
+	// one key only.
+	ListMetadataItems(ctx context.Context, fileID, key string, limit, offset int) ([]MetadataItem, error)
+
+	// WithTx runs fn inside a transaction, committing if it returns nil and
+	// rolling back otherwise. Backends without transactions may run fn
+	// directly against the store.
+	WithTx(ctx context.Context, fn func(RepositoryTx) error) error
 }
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SettingsStore persists runtime settings as string key/value pairs.
+type SettingsStore interface {
+	// GetSetting returns the stored value for key, or sql.ErrNoRows if unset.
+	GetSetting(ctx context.Context, key string) (string, error)
+
+	// SetSetting stores value for key, replacing any previous value.
+	SetSetting(ctx context.Context, key, value string) error
+
+	// ListSettings returns every stored setting.
+	ListSettings(ctx context.Context) (map[string]string, error)
+}
+
+// MySQLSettings implements SettingsStore on the settings table.
+type MySQLSettings struct {
+	stmtGet  *sql.Stmt
+	stmtSet  *sql.Stmt
+	stmtList *sql.Stmt
+}
+
+// NewMySQLSettings prepares the settings statements.
+func NewMySQLSettings(db *sql.DB) (*MySQLSettings, error) {
+	stmtGet, err := db.Prepare("SELECT value FROM settings WHERE name = ?")
+	if err != nil {
+		return nil, fmt.Errorf("prepare settingGet: %w", err)
+	}
+
+	stmtSet, err := db.Prepare("INSERT INTO settings (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value)")
+	if err != nil {
+		return nil, fmt.Errorf("prepare settingSet: %w", err)
+	}
+
+	stmtList, err := db.Prepare("SELECT name, value FROM settings")
+	if err != nil {
+		return nil, fmt.Errorf("prepare settingList: %w", err)
+	}
+
+	return &MySQLSettings{
+		stmtGet:  stmtGet,
+		stmtSet:  stmtSet,
+		stmtList: stmtList,
+	}, nil
+}
+
+// GetSetting reads a single setting.
+func (s *MySQLSettings) GetSetting(ctx context.Context, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var value string
+	if err := s.stmtGet.QueryRowContext(ctx, key).Scan(&value); err != nil {
+		return "", fmt.Errorf("repo settingGet: %w", err)
+	}
+	return value, nil
+}
+
+// SetSetting upserts a setting.
+func (s *MySQLSettings) SetSetting(ctx context.Context, key, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	if _, err := s.stmtSet.ExecContext(ctx, key, value); err != nil {
+		return fmt.Errorf("repo settingSet: %w", err)
+	}
+	return nil
+}
+
+// ListSettings reads every stored setting.
+func (s *MySQLSettings) ListSettings(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := s.stmtList.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repo settingList: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("repo settingList scan: %w", err)
+		}
+		out[key] = value
+	}
+	return out, rows.Err()
+}
+
+// Close releases all prepared statements.
+func (s *MySQLSettings) Close() error {
+	for _, st := range []*sql.Stmt{s.stmtGet, s.stmtSet, s.stmtList} {
+		if st != nil {
+			st.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import "unicode/utf8"
+
+// File processing statuses.
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// MaxFailureReasonLen is the width of the failure_reason column in bytes.
+const MaxFailureReasonLen = 1024
+
+// TruncateReason shortens reason to at most max bytes without splitting a
+// UTF-8 sequence, marking the cut with "...". Non-positive max or a value
+// above MaxFailureReasonLen selects MaxFailureReasonLen.
+func TruncateReason(reason string, max int) string {
+	if max <= 0 || max > MaxFailureReasonLen {
+		max = MaxFailureReasonLen
+	}
+	if len(reason) <= max {
+		return reason
+	}
+	const ellipsis = "..."
+	if max <= len(ellipsis) {
+		return reason[:max]
+	}
+	cut := max - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(reason[cut]) {
+		cut--
+	}
+	return reason[:cut] + ellipsis
+}
+
+// statusTransitions holds the manual status changes UpdateStatus callers may
+// make: a pending file may be cancelled to failed and a failed one requeued.
+// Only the worker completes a file, through CompleteProcessing, so completed
+// is never a manual target; it is terminal.
+var statusTransitions = map[string][]string{
+	StatusPending:   {StatusFailed},
+	StatusFailed:    {StatusPending},
+	StatusCompleted: nil,
+}
+
+// IsValidStatus reports whether s is a known file status.
+func IsValidStatus(s string) bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// IsTerminal reports whether a file in status s can never change status
+// again.
+func IsTerminal(s string) bool {
+	next, ok := statusTransitions[s]
+	return ok && len(next) == 0
+}
+
+// CanTransition reports whether a file may move from status from to status
+// to. Setting the current status again is always allowed.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return IsValidStatus(to)
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// WithTx runs fn inside a database transaction. The transaction is committed
+// when fn returns nil and rolled back on error or panic. dbTimeout bounds the
+// whole transaction rather than each statement.
+func (r *MySQLRepo) WithTx(ctx context.Context, fn func(RepositoryTx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repo tx begin: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	if err := fn(&mysqlTx{tx: tx, repo: r}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repo tx commit: %w", err)
+	}
+	return nil
+}
+
+// mysqlTx implements RepositoryTx by binding MySQLRepo's prepared statements
+// to an open transaction.
+type mysqlTx struct {
+	tx   *sql.Tx
+	repo *MySQLRepo
+}
+
+func (t *mysqlTx) Create(ctx context.Context, rec *FileRecord) error {
+	if err := t.repo.policy.check(rec.Size, ""); err != nil {
+		return fmt.Errorf("repo tx create: %w", err)
+	}
+	_, err := t.tx.StmtContext(ctx, t.repo.stmtCreate).ExecContext(ctx, rec.ID, rec.Hash, rec.Size, rec.Status, rec.FilePath, rec.Owner, nullTime(rec.ExpiresAt), rec.OriginalName)
+	if err != nil {
+		return fmt.Errorf("repo tx create: %w", err)
+	}
+	return nil
+}
+
+func (t *mysqlTx) Upsert(ctx context.Context, rec *FileRecord) error {
+	if err := t.repo.policy.check(rec.Size, metaMimeType(rec.Metadata)); err != nil {
+		return fmt.Errorf("repo tx upsert: %w", err)
+	}
+	metaJSON, err := json.Marshal(rec.Metadata)
+	if err != nil {
+		return fmt.Errorf("repo tx upsert marshal: %w", err)
+	}
+
+	_, err = t.tx.StmtContext(ctx, t.repo.stmtUpsert).ExecContext(ctx, rec.ID, rec.Hash, rec.Size, rec.Status, rec.FilePath, rec.Owner, nullTime(rec.ExpiresAt), metaJSON, rec.OriginalName, metaMimeType(rec.Metadata))
+	if err != nil {
+		return fmt.Errorf("repo tx upsert: %w", err)
+	}
+	return nil
+}
+
+func (t *mysqlTx) Delete(ctx context.Context, id string) error {
+	res, err := t.tx.StmtContext(ctx, t.repo.stmtDelete).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("repo tx delete: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repo tx delete: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (t *mysqlTx) UpdateStatus(ctx context.Context, id, status string) error {
+	if _, err := t.tx.StmtContext(ctx, t.repo.stmtUpdStat).ExecContext(ctx, status, id); err != nil {
+		return fmt.Errorf("repo tx updateStatus: %w", err)
+	}
+	return nil
+}
+
+func (t *mysqlTx) UpdateMetadata(ctx context.Context, id, hash string, size int64, meta map[string]interface{}) error {
+	if err := t.repo.policy.check(size, metaMimeType(meta)); err != nil {
+		return fmt.Errorf("repo tx updateMetadata: %w", err)
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("repo tx updateMetadata marshal: %w", err)
+	}
+
+	if _, err := t.tx.StmtContext(ctx, t.repo.stmtUpdMeta).ExecContext(ctx, hash, size, metaJSON, metaMimeType(meta), id); err != nil {
+		return fmt.Errorf("repo tx updateMetadata: %w", err)
+	}
+	return nil
+}
+
+func (t *mysqlTx) CompleteProcessing(ctx context.Context, id string, epoch int64, hash string, size int64, meta map[string]interface{}) error {
+	if err := t.repo.policy.check(size, metaMimeType(meta)); err != nil {
+		return fmt.Errorf("repo tx completeProcessing: %w", err)
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("repo tx completeProcessing marshal: %w", err)
+	}
+
+	res, err := t.tx.StmtContext(ctx, t.repo.stmtDone).ExecContext(ctx, hash, size, metaJSON, metaMimeType(meta), StatusCompleted, id, epoch)
+	if err != nil {
+		return fmt.Errorf("repo tx completeProcessing: %w", err)
+	}
+	return checkEpoch(ctx, t.tx, res, id, epoch, "tx completeProcessing")
+}
+
+func (t *mysqlTx) MarkFailed(ctx context.Context, id string, epoch int64, reason string) error {
+	res, err := t.tx.StmtContext(ctx, t.repo.stmtFailed).ExecContext(ctx, StatusFailed, TruncateReason(reason, MaxFailureReasonLen), id, epoch)
+	if err != nil {
+		return fmt.Errorf("repo tx markFailed: %w", err)
+	}
+	return checkEpoch(ctx, t.tx, res, id, epoch, "tx markFailed")
+}
+
+func (t *mysqlTx) UpdateFilePath(ctx context.Context, id, path string) error {
+	if _, err := t.tx.StmtContext(ctx, t.repo.stmtUpdPath).ExecContext(ctx, path, id); err != nil {
+		return fmt.Errorf("repo tx updateFilePath: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package restapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader echoes the request ID to clients so a report can be matched
+// to the server logs.
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// requestIDFrom returns the ID AccessLog assigned to r, or a fresh one when
+// the handler is served without the middleware.
+func requestIDFrom(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// statusRecorder captures the status code and body size a handler writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach Flush and the deadline setters.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// AccessLog wraps next so every request gets an ID, shared with the handler's
+// own logs, and one access-log line once the response is complete.
+func (h *Handler) AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := requestIDFrom(r)
+		w.Header().Set(requestIDHeader, requestID)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+
+		status := rec.status
+		if status == 0 {
+			// Nothing written at all: net/http sends an empty 200.
+			status = http.StatusOK
+		}
+		h.logger.Info("http request",
+			slog.String("request_id", requestID),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
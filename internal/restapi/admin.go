@@ -0,0 +1,65 @@
+package restapi
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// requireAdmin wraps an admin-only handler, rejecting callers without the admin token.
+func (h *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.isAdmin(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ---------- GET /admin/pool ----------
+
+// poolStats reports per-worker counters so a stuck or slow worker stands out.
+func (h *Handler) poolStats(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{
+		"workers":     h.pool.Stats(),
+		"rolling":     h.pool.Metrics(),
+		"paused":      h.pool.Paused(),
+		"queue_depth": h.pool.QueueDepth(),
+		"queue_lanes": h.pool.LaneDepths(),
+	}
+	if h.cfg.Recovery != nil {
+		body["recovery"] = h.cfg.Recovery.Snapshot()
+	}
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+// ---------- POST /admin/pause, POST /admin/resume ----------
+
+// pausePool stops workers from taking new jobs; uploads keep queueing.
+func (h *Handler) pausePool(w http.ResponseWriter, r *http.Request) {
+	h.pool.Pause()
+	h.logger.Info("worker pool paused", slog.Int("queue_depth", h.pool.QueueDepth()))
+	h.writePoolState(w, r)
+}
+
+// resumePool lets workers drain the queued backlog.
+func (h *Handler) resumePool(w http.ResponseWriter, r *http.Request) {
+	h.pool.Resume()
+	h.logger.Info("worker pool resumed", slog.Int("queue_depth", h.pool.QueueDepth()))
+	h.writePoolState(w, r)
+}
+
+func (h *Handler) writePoolState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"paused":      h.pool.Paused(),
+		"queue_depth": h.pool.QueueDepth(),
+	})
+}
+
+// ---------- POST /admin/pool/reset ----------
+
+func (h *Handler) resetPoolStats(w http.ResponseWriter, r *http.Request) {
+	h.pool.ResetStats()
+	h.logger.Info("worker pool stats reset")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// batchGetRequest is the body of POST /files/batch-get.
+type batchGetRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// ---------- POST /files/batch-get ----------
+
+// batchGetFiles returns the records for up to repository.MaxBatchIDs IDs in
+// one round trip, keyed by ID. Missing IDs, and those the caller may not
+// see, map to null so the two cases cannot be told apart.
+func (h *Handler) batchGetFiles(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	var req batchGetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", "body must be a JSON object with an ids array")
+		return
+	}
+	if len(req.IDs) > repository.MaxBatchIDs {
+		writeAPIError(w, http.StatusBadRequest, "too_many_ids", fmt.Sprintf("at most %d ids per request", repository.MaxBatchIDs))
+		return
+	}
+
+	logger.Info("batch get request", slog.Int("ids", len(req.IDs)))
+
+	records, err := h.repo.GetByIDs(r.Context(), req.IDs)
+	if err != nil {
+		logger.Error("get by ids", slog.String("error", err.Error()))
+		writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		return
+	}
+
+	result := make(map[string]interface{}, len(req.IDs))
+	for _, id := range req.IDs {
+		if rec, ok := records[id]; ok && h.canAccess(r, rec) {
+			result[id] = recordToMap(rec)
+		} else {
+			result[id] = nil
+		}
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,31 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mtiwari1/gopherdrive/internal/metaschema"
+)
+
+// parseClientMetadata decodes the upload's "metadata" form field, which must
+// be a JSON object within Config.MetadataLimits and free of reserved keys.
+// An empty field yields nil.
+func (h *Handler) parseClientMetadata(raw string) (map[string]interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if !json.Valid([]byte(raw)) {
+		return nil, errors.New("metadata is not valid JSON")
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil || meta == nil {
+		return nil, errors.New("metadata must be a JSON object")
+	}
+	if err := h.cfg.MetadataLimits.Check(meta); err != nil {
+		return nil, err
+	}
+	if err := metaschema.CheckClientKeys(meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
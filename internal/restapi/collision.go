@@ -0,0 +1,99 @@
+package restapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// CollisionStrategy decides what happens when an upload's destination path
+// already exists on disk.
+type CollisionStrategy string
+
+const (
+	// CollisionError rejects the upload with 409 Conflict. This is the default.
+	CollisionError CollisionStrategy = "error"
+	// CollisionOverwrite replaces the existing file.
+	CollisionOverwrite CollisionStrategy = "overwrite"
+	// CollisionSkip keeps the existing file and discards the new upload,
+	// treating the collision as a deduplication hit.
+	CollisionSkip CollisionStrategy = "skip"
+)
+
+// errDestExists is returned by placeFile under CollisionError.
+var errDestExists = errors.New("destination file already exists")
+
+// linkFile is os.Link, swapped out by tests to simulate filesystems without
+// hard links.
+var linkFile = os.Link
+
+// ParseCollisionStrategy validates a strategy name; empty selects CollisionError.
+func ParseCollisionStrategy(s string) (CollisionStrategy, error) {
+	switch cs := CollisionStrategy(s); cs {
+	case "":
+		return CollisionError, nil
+	case CollisionError, CollisionOverwrite, CollisionSkip:
+		return cs, nil
+	default:
+		return "", fmt.Errorf("unknown collision strategy %q (want error, overwrite or skip)", s)
+	}
+}
+
+// placeFile moves the staged tmpPath to destPath according to strategy. It
+// reports skipped=true when the existing file was kept. tmpPath is always
+// consumed: renamed into place or removed.
+func placeFile(tmpPath, destPath string, strategy CollisionStrategy) (skipped bool, err error) {
+	if strategy == CollisionOverwrite {
+		if err := os.Rename(tmpPath, destPath); err != nil {
+			os.Remove(tmpPath)
+			return false, err
+		}
+		return false, nil
+	}
+
+	// os.Link fails with ErrExist instead of replacing, which makes the
+	// existence check and the placement a single atomic step. Filesystems
+	// without hard links get the same guarantee from an O_EXCL create.
+	err = linkFile(tmpPath, destPath)
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOTSUP) {
+		err = copyExclusive(tmpPath, destPath)
+	}
+	os.Remove(tmpPath)
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, os.ErrExist) && strategy == CollisionSkip:
+		return true, nil
+	case errors.Is(err, os.ErrExist):
+		return false, errDestExists
+	default:
+		return false, err
+	}
+}
+
+// copyExclusive copies src to a new file at dest, failing with ErrExist if
+// dest already exists. A partial copy is removed.
+func copyExclusive(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) // as os.CreateTemp
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return nil
+}
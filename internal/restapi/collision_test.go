@@ -0,0 +1,89 @@
+package restapi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// stageFiles writes a staged upload and, if existing is non-nil, a file
+// already at the destination.
+func stageFiles(t *testing.T, existing []byte) (tmpPath, destPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	tmpPath = filepath.Join(dir, "upload.tmp")
+	destPath = filepath.Join(dir, "dest")
+	if err := os.WriteFile(tmpPath, []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if existing != nil {
+		if err := os.WriteFile(destPath, existing, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpPath, destPath
+}
+
+func TestPlaceFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    CollisionStrategy
+		existing    []byte
+		wantSkipped bool
+		wantErr     error
+		wantContent string
+	}{
+		{"error, free", CollisionError, nil, false, nil, "new"},
+		{"error, taken", CollisionError, []byte("old"), false, errDestExists, "old"},
+		{"overwrite, free", CollisionOverwrite, nil, false, nil, "new"},
+		{"overwrite, taken", CollisionOverwrite, []byte("old"), false, nil, "new"},
+		{"skip, free", CollisionSkip, nil, false, nil, "new"},
+		{"skip, taken", CollisionSkip, []byte("old"), true, nil, "old"},
+	}
+	for _, link := range []struct {
+		name string
+		fn   func(string, string) error
+	}{
+		{"hard link", os.Link},
+		{"no hard links", func(oldname, newname string) error {
+			return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EPERM}
+		}},
+	} {
+		for _, tt := range tests {
+			t.Run(link.name+"/"+tt.name, func(t *testing.T) {
+				defer func(orig func(string, string) error) { linkFile = orig }(linkFile)
+				linkFile = link.fn
+
+				tmpPath, destPath := stageFiles(t, tt.existing)
+				skipped, err := placeFile(tmpPath, destPath, tt.strategy)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if skipped != tt.wantSkipped {
+					t.Errorf("skipped = %v, want %v", skipped, tt.wantSkipped)
+				}
+				got, err := os.ReadFile(destPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.wantContent {
+					t.Errorf("dest = %q, want %q", got, tt.wantContent)
+				}
+				if _, err := os.Stat(tmpPath); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("staged file not consumed: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestParseCollisionStrategy(t *testing.T) {
+	if cs, err := ParseCollisionStrategy(""); err != nil || cs != CollisionError {
+		t.Errorf(`ParseCollisionStrategy("") = %q, %v; want error strategy`, cs, err)
+	}
+	if _, err := ParseCollisionStrategy("rename"); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
@@ -0,0 +1,66 @@
+package restapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+const (
+	defaultRangeLimit = 100
+	maxRangeLimit     = 1000
+)
+
+// dateRangeQuery is GET /files filtered by creation time and status.
+type dateRangeQuery struct {
+	from, to      time.Time
+	status        string
+	limit, offset int
+}
+
+// parseDateRange reads from, to (RFC 3339), status, limit and offset. ok is
+// false when none of from, to or status is given, leaving GET /files to its
+// other filters. A missing from is open-ended; a missing to means now.
+func parseDateRange(r *http.Request) (q dateRangeQuery, ok bool, err error) {
+	v := r.URL.Query()
+	if v.Get("from") == "" && v.Get("to") == "" && v.Get("status") == "" {
+		return q, false, nil
+	}
+
+	q.from = time.Unix(0, 0).UTC()
+	if s := v.Get("from"); s != "" {
+		if q.from, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, true, errors.New("from must be an RFC 3339 timestamp")
+		}
+	}
+	q.to = time.Now().UTC()
+	if s := v.Get("to"); s != "" {
+		if q.to, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, true, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+	if q.from.After(q.to) {
+		return q, true, errors.New("from must not be after to")
+	}
+
+	if q.status = v.Get("status"); q.status != "" && !repository.IsValidStatus(q.status) {
+		return q, true, fmt.Errorf("unknown status %q", q.status)
+	}
+
+	q.limit = defaultRangeLimit
+	if s := v.Get("limit"); s != "" {
+		if q.limit, err = strconv.Atoi(s); err != nil || q.limit < 1 || q.limit > maxRangeLimit {
+			return q, true, fmt.Errorf("limit must be between 1 and %d", maxRangeLimit)
+		}
+	}
+	if s := v.Get("offset"); s != "" {
+		if q.offset, err = strconv.Atoi(s); err != nil || q.offset < 0 {
+			return q, true, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return q, true, nil
+}
@@ -0,0 +1,66 @@
+package restapi
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// uploadDedup catches rapid double-submits of the same upload (browser
+// retries) before the content hash exists. Uploads are fingerprinted by
+// client, filename and size; a second one within Config.DedupWindow is
+// answered with the first upload's ID. It is a heuristic: two distinct files
+// that share all three inside the window are treated as one.
+type uploadDedup struct {
+	mu        sync.Mutex
+	seen      map[string]dedupEntry
+	lastSweep time.Time
+}
+
+type dedupEntry struct {
+	id string
+	at time.Time
+}
+
+// uploadFingerprint keys an upload for the dedup window.
+func uploadFingerprint(owner, filename string, size int64) string {
+	return owner + "\x00" + filename + "\x00" + strconv.FormatInt(size, 10)
+}
+
+// claim returns the ID of an upload with the same key seen within window,
+// if any. Otherwise it records id under key and returns "". The claim is
+// taken before the upload is stored, so a concurrent duplicate already maps
+// to id while the first request is still in flight.
+func (d *uploadDedup) claim(key, id string, window time.Duration, now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastSweep) >= window {
+		for k, e := range d.seen {
+			if now.Sub(e.at) >= window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if e, ok := d.seen[key]; ok && now.Sub(e.at) < window {
+		return e.id
+	}
+	if d.seen == nil {
+		d.seen = make(map[string]dedupEntry)
+	}
+	d.seen[key] = dedupEntry{id: id, at: now}
+	return ""
+}
+
+// release drops the claim for key if it still belongs to id, so that a
+// retry after a failed upload is not answered with an ID that was never
+// stored.
+func (d *uploadDedup) release(key, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.seen[key]; ok && e.id == id {
+		delete(d.seen, key)
+	}
+}
@@ -0,0 +1,61 @@
+package restapi
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/mtiwari1/gopherdrive/internal/hasher"
+)
+
+// ---------- GET /files/{id}/digest ----------
+
+// getDigest returns the stored hash in the encodings other tools expect: hex,
+// standard base64, and an OCI-style "<algorithm>:<hex>" digest. Files hashed
+// with the tree scheme report "sha256-tree" as their algorithm, since their
+// digest is not a plain SHA-256 of the content.
+func (h *Handler) getDigest(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	id := r.PathValue("id")
+	logger.Info("get digest request", slog.String("file_id", id))
+
+	rec, err := h.repo.GetByID(r.Context(), id)
+	if err == nil && !h.canAccess(r, rec) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "file not found")
+		} else {
+			logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
+			writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		}
+		return
+	}
+
+	raw, err := hex.DecodeString(rec.Hash)
+	if rec.Hash == "" || err != nil {
+		// Pending files have no hash yet.
+		writeAPIError(w, http.StatusConflict, "digest_unavailable", "file is "+rec.Status+", digest not available")
+		return
+	}
+
+	algorithm := hasher.HashSchemeSHA256
+	if scheme, ok := rec.Metadata["hash_scheme"].(string); ok && scheme != "" {
+		algorithm = scheme
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"id":        rec.ID,
+		"algorithm": algorithm,
+		"hex":       rec.Hash,
+		"base64":    base64.StdEncoding.EncodeToString(raw),
+		"digest":    algorithm + ":" + rec.Hash,
+		"size":      rec.Size,
+	})
+}
@@ -0,0 +1,118 @@
+package restapi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+	"github.com/mtiwari1/gopherdrive/internal/settings"
+)
+
+// ---------- GET /files/{id}/content ----------
+
+// downloadFile streams a completed file. Stored content is immutable and
+// addressed by its SHA-256, so the hash doubles as a strong ETag and responses
+// may be cached for Config.DownloadMaxAge (or its runtime setting). http.ServeContent handles
+// If-None-Match, If-Modified-Since and Range requests.
+func (h *Handler) downloadFile(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	id := r.PathValue("id")
+	logger.Info("download file request", slog.String("file_id", id))
+
+	rec, err := h.repo.GetByID(r.Context(), id)
+	if err == nil && !h.canAccess(r, rec) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "file not found", http.StatusNotFound)
+		} else {
+			logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Only completed files have verified content; never serve a partial upload.
+	if rec.Status != repository.StatusCompleted {
+		http.Error(w, fmt.Sprintf("file is %s, content not available", rec.Status), http.StatusConflict)
+		return
+	}
+
+	if err := h.cfg.FileLimiter.Acquire(r.Context(), 1); err != nil {
+		logger.Warn("gave up waiting for a file handle", slog.String("error", err.Error()))
+		http.Error(w, "too many open files", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.cfg.FileLimiter.Release(1)
+
+	f, err := os.Open(rec.FilePath)
+	if err != nil {
+		logger.Error("open file", slog.String("file_id", id), slog.String("error", err.Error()))
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "file content missing", http.StatusNotFound)
+		} else {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+
+	mimeType, _ := rec.Metadata["mime_type"].(string)
+	if mimeType != "" {
+		w.Header().Set("Content-Type", mimeType)
+	}
+
+	name := rec.OriginalName
+	if name == "" {
+		name = filepath.Base(rec.FilePath)
+	}
+	if ext, _ := rec.Metadata["inferred_extension"].(string); ext != "" && filepath.Ext(name) == "" {
+		name += ext
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(dispositionFor(r, mimeType), map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+rec.Hash+`"`)
+	// Files are owner-scoped, so shared caches must not store them.
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, immutable", int(h.cfg.Settings.Duration(settings.DownloadMaxAge, h.cfg.DownloadMaxAge).Seconds())))
+
+	http.ServeContent(w, r, filepath.Base(rec.FilePath), rec.CreatedAt, f)
+}
+
+// dispositionFor picks "inline" or "attachment". ?download=true|false wins;
+// otherwise images and text render inline and everything else downloads.
+// Active content (HTML, SVG) is always an attachment so an uploaded file can
+// never run script in the API's origin.
+func dispositionFor(r *http.Request, mimeType string) string {
+	mt, _, _ := strings.Cut(mimeType, ";")
+	mt = strings.TrimSpace(strings.ToLower(mt))
+
+	switch mt {
+	case "text/html", "image/svg+xml", "application/xhtml+xml", "text/xml", "application/xml":
+		return "attachment"
+	}
+
+	if v := r.URL.Query().Get("download"); v != "" {
+		if download, err := strconv.ParseBool(v); err == nil {
+			if download {
+				return "attachment"
+			}
+			return "inline"
+		}
+	}
+
+	if strings.HasPrefix(mt, "image/") || strings.HasPrefix(mt, "text/") {
+		return "inline"
+	}
+	return "attachment"
+}
@@ -0,0 +1,62 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// apiError is the structured JSON error body: {"error": {"code": ..., "message": ...}}.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeAPIError sends a structured JSON error with the given HTTP status.
+// code is a stable machine-readable identifier; message is for humans.
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]apiError{
+		"error": {Code: code, Message: message},
+	})
+}
+
+// classifyFormFileError maps an r.FormFile failure to a specific status, code
+// and message so client developers can tell why an upload was rejected.
+func classifyFormFileError(r *http.Request, field string, err error) (status int, code, message string) {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		return http.StatusRequestEntityTooLarge, "request_too_large",
+			"request body exceeds the upload size limit"
+	case errors.Is(err, http.ErrNotMultipart):
+		return http.StatusBadRequest, "not_multipart",
+			"Content-Type must be multipart/form-data"
+	case errors.Is(err, http.ErrMissingBoundary):
+		return http.StatusBadRequest, "missing_boundary",
+			"multipart Content-Type has no boundary parameter"
+	case errors.Is(err, multipart.ErrMessageTooLarge):
+		return http.StatusBadRequest, "too_many_parts",
+			"multipart form has too many parts or headers"
+	case errors.Is(err, http.ErrMissingFile):
+		if r.MultipartForm != nil && len(r.MultipartForm.File) > 0 {
+			got := make([]string, 0, len(r.MultipartForm.File))
+			for name := range r.MultipartForm.File {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			return http.StatusBadRequest, "unexpected_field",
+				"expected file in field \"" + field + "\", got: " + strings.Join(got, ", ")
+		}
+		return http.StatusBadRequest, "missing_file_field",
+			"multipart form has no \"" + field + "\" file field"
+	default:
+		return http.StatusBadRequest, "malformed_multipart",
+			"malformed multipart body: " + err.Error()
+	}
+}
@@ -0,0 +1,85 @@
+package restapi
+
+import (
+	"encoding/csv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// exportColumns is the header row of GET /files/export.csv.
+var exportColumns = []string{"id", "hash", "size", "status", "mime_type", "created_at"}
+
+// exportRowWindow is how long each row may take to reach the client. It
+// replaces the server-wide write timeout, which would cut off large exports.
+const exportRowWindow = 30 * time.Second
+
+// ---------- GET /files/export.csv ----------
+
+// exportCSV streams the caller's catalog (every file for admins) as CSV,
+// flushing each row so memory stays flat. The status line is sent before
+// the first row, so an error mid-stream truncates the body; it is logged.
+func (h *Handler) exportCSV(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	owner := clientID(r)
+	if h.isAdmin(r) {
+		owner = "" // admins export every owner
+	}
+	logger.Info("export catalog request", slog.String("owner", owner))
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", mimeCSV)
+	w.Header().Set("Content-Disposition", `attachment; filename="files.csv"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	cw := csv.NewWriter(w)
+	flush := func() error {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := cw.Write(exportColumns); err != nil {
+		logger.Error("export header", slog.String("error", err.Error()))
+		return
+	}
+
+	rows := 0
+	row := make([]string, len(exportColumns))
+	err := h.repo.Iterate(r.Context(), owner, func(rec *repository.FileRecord) error {
+		// Stop as soon as the client goes away.
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := rc.SetWriteDeadline(time.Now().Add(exportRowWindow)); err != nil {
+			logger.Warn("set export write deadline", slog.String("error", err.Error()))
+		}
+
+		row[0] = rec.ID
+		row[1] = rec.Hash
+		row[2] = strconv.FormatInt(rec.Size, 10)
+		row[3] = rec.Status
+		row[4] = rec.MimeType
+		row[5] = rec.CreatedAt.UTC().Format(time.RFC3339)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+		rows++
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		logger.Warn("export aborted", slog.Int("rows", rows), slog.String("error", err.Error()))
+		return
+	}
+	logger.Info("export completed", slog.Int("rows", rows))
+}
@@ -0,0 +1,76 @@
+package restapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// apiFields is every key recordToMap emits; ?fields= may select any of them.
+var apiFields = map[string]bool{
+	"id":             true,
+	"seq":            true,
+	"hash":           true,
+	"size":           true,
+	"status":         true,
+	"failure_reason": true,
+	"file_path":      true,
+	"created_at":     true,
+	"metadata":       true,
+}
+
+// parseFields reads the comma-separated ?fields= projection. It returns nil,
+// meaning every field, when the parameter is absent or empty.
+func parseFields(r *http.Request) ([]string, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get("fields"))
+	if raw == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !apiFields[f] {
+			known := make([]string, 0, len(apiFields))
+			for k := range apiFields {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown field %q (known: %s)", f, strings.Join(known, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// project keeps only fields of rec, in place. Nil fields keeps everything.
+func project(rec map[string]interface{}, fields []string) map[string]interface{} {
+	if fields == nil {
+		return rec
+	}
+	keep := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		keep[f] = true
+	}
+	for k := range rec {
+		if !keep[k] {
+			delete(rec, k)
+		}
+	}
+	return rec
+}
+
+// presentFields returns the recordFields columns rec still has after
+// projection, in their fixed order.
+func presentFields(rec map[string]interface{}) []string {
+	cols := make([]string, 0, len(recordFields))
+	for _, f := range recordFields {
+		if _, ok := rec[f]; ok {
+			cols = append(cols, f)
+		}
+	}
+	return cols
+}
@@ -0,0 +1,46 @@
+package restapi
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/mtiwari1/gopherdrive/internal/eventlog"
+)
+
+// ---------- GET /files/{id}/log ----------
+
+// getFileLog returns the retained processing events for a file, oldest
+// first. History is kept in memory by the instance that processed the file.
+func (h *Handler) getFileLog(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	id := r.PathValue("id")
+	logger.Info("get file log request", slog.String("file_id", id))
+
+	rec, err := h.repo.GetByID(r.Context(), id)
+	if err == nil && !h.canAccess(r, rec) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "file not found")
+		} else {
+			logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
+			writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		}
+		return
+	}
+
+	events := h.cfg.Events.Events(id)
+	if events == nil {
+		events = []eventlog.Event{}
+	}
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"id":     rec.ID,
+		"status": rec.Status,
+		"events": events,
+	})
+}
@@ -4,34 +4,199 @@ package restapi
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/mtiwari1/gopherdrive/internal/diskguard"
+	"github.com/mtiwari1/gopherdrive/internal/eventlog"
+	"github.com/mtiwari1/gopherdrive/internal/fdlimit"
+	"github.com/mtiwari1/gopherdrive/internal/hasher"
+	"github.com/mtiwari1/gopherdrive/internal/idgen"
+	"github.com/mtiwari1/gopherdrive/internal/metaschema"
+	"github.com/mtiwari1/gopherdrive/internal/mimestats"
 	"github.com/mtiwari1/gopherdrive/internal/repository"
+	"github.com/mtiwari1/gopherdrive/internal/settings"
 	"github.com/mtiwari1/gopherdrive/internal/worker"
 	pb "github.com/mtiwari1/gopherdrive/proto"
 
+	"golang.org/x/sync/semaphore"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// maxUploadBytes caps request bodies for uploads and hashing (32 MB).
+const maxUploadBytes = 32 << 20
+
+// Multipart defaults used when Config leaves the limits at zero.
+const (
+	defaultMultipartMemory = 10 << 20
+	defaultMaxFormField    = 8 << 10
+	defaultUploadField     = "file"
+)
+
+// Config holds tunables for the REST handler. The zero value disables all limits.
+type Config struct {
+	// MaxConcurrentUploads bounds how many uploads are streamed to disk at once.
+	// Zero means unlimited.
+	MaxConcurrentUploads int64
+
+	// UploadSlotWait is how long an upload waits for a free slot before the
+	// request is rejected with 503.
+	UploadSlotWait time.Duration
+
+	// UploadTimeout replaces the server-wide read and write timeouts for
+	// POST /files, so large uploads on slow links are not cut off. Zero keeps
+	// the server timeouts.
+	UploadTimeout time.Duration
+
+	// DefaultQuotaBytes is the storage allowance for clients without an entry
+	// in ClientQuotas. Zero means unlimited.
+	DefaultQuotaBytes int64
+
+	// ClientQuotas overrides the default allowance per client identity.
+	ClientQuotas map[string]int64
+
+	// DefaultTTL is applied to uploads that do not pass a "ttl" form field.
+	// Zero means files are kept forever.
+	DefaultTTL time.Duration
+
+	// CollisionStrategy decides what happens when the destination path of an
+	// upload already exists. Empty behaves like CollisionError.
+	CollisionStrategy CollisionStrategy
+
+	// StorageKey lays out stored files below the upload directory. Nil
+	// selects DefaultStorageKey.
+	StorageKey *StorageKeyTemplate
+
+	// DownloadMaxAge is the Cache-Control max-age sent with file downloads.
+	DownloadMaxAge time.Duration
+
+	// StaticDir overrides the dashboard with an on-disk directory served at
+	// "/". If it is empty or missing, StaticFS is served instead.
+	StaticDir string
+
+	// StaticFS holds the embedded dashboard assets. With neither StaticDir
+	// nor StaticFS available, static serving is disabled.
+	StaticFS fs.FS
+
+	// AdminToken lets callers bypass owner scoping via the X-Admin-Token
+	// header. Empty disables admin access.
+	AdminToken string
+
+	// IsLeader, when set, reports whether this replica runs the singleton
+	// background tasks; /healthz includes it.
+	IsLeader func() bool
+
+	// Events holds per-file processing history for GET /files/{id}/log.
+	// Nil disables recording and the endpoint returns no events.
+	Events *eventlog.Log
+
+	// MultipartMaxMemory is how much of an upload form is held in memory
+	// before file parts spill to temporary files. Zero selects 10 MB.
+	MultipartMaxMemory int64
+
+	// MaxFormFieldBytes caps each non-file form field of an upload (such as
+	// "options" or "ttl"). Larger fields are rejected with 400. Zero
+	// selects 8 KB.
+	MaxFormFieldBytes int64
+
+	// UploadField names the multipart field holding the upload. Empty
+	// selects "file".
+	UploadField string
+
+	// AnyUploadField accepts a file part under any field name when none is
+	// sent under UploadField. With several, the alphabetically first field
+	// wins, since multipart.Form does not keep part order.
+	AnyUploadField bool
+
+	// Settings supplies runtime overrides for DefaultQuotaBytes and
+	// DownloadMaxAge and backs /admin/settings. Nil uses Config as is.
+	Settings *settings.Store
+
+	// OverflowPolicy decides what an upload does when the in-memory worker
+	// queue is full. Empty behaves like OverflowBlock. Ignored when JobQueue
+	// is set.
+	OverflowPolicy OverflowPolicy
+
+	// SpillQueue receives jobs that overflow the pool under OverflowSpill.
+	SpillQueue repository.JobQueue
+
+	// JobQueue, when set, receives upload jobs instead of the in-process
+	// pool, making them durable and shareable across instances.
+	JobQueue repository.JobQueue
+
+	// FileLimiter bounds open file handles shared with the worker pool.
+	// Uploads and downloads wait for a slot while the client is connected.
+	// Nil means unlimited.
+	FileLimiter *fdlimit.Limiter
+
+	// MimeVolume, when set, is reported under /admin/metrics. The results
+	// handler feeds it as files finish processing.
+	MimeVolume *mimestats.Counter
+
+	// DiskGuard, when set, refuses uploads with 507 while the upload volume
+	// is above its high-water mark. Reads are unaffected.
+	DiskGuard *diskguard.Guard
+
+	// IDGenerator names new uploads. Nil selects random UUIDv4s;
+	// idgen.UUIDv7 makes IDs, and so ID-ordered listings, chronological.
+	IDGenerator idgen.Generator
+
+	// DBStats, when set, reports the database connection pool under
+	// /admin/metrics (normally (*sql.DB).Stats).
+	DBStats func() sql.DBStats
+
+	// Recovery, when set, reports startup re-submission of pending files
+	// under /admin/pool. It is nil with the durable queue, which needs none.
+	Recovery *worker.RecoveryProgress
+
+	// RegisterRetry sets retries and the circuit breaker around the
+	// RegisterFile call made by uploads. The zero value tries once.
+	RegisterRetry RegisterRetry
+
+	// DedupWindow, when positive, answers an upload with the same client,
+	// filename and size as one accepted less than DedupWindow ago with the
+	// earlier file's ID instead of storing it again. Zero disables it.
+	DedupWindow time.Duration
+
+	// MetadataLimits bounds the JSON object an upload may send in its
+	// "metadata" form field. Its byte size is capped by MaxFormFieldBytes.
+	MetadataLimits metaschema.Limits
+}
+
 // Handler holds dependencies for REST endpoints.
 type Handler struct {
 	grpc      pb.GopherDriveServer
 	repo      repository.Repository
 	pool      *worker.Pool
 	uploadDir string
-	db        *sql.DB
 	logger    *slog.Logger
+	cfg       Config
+
+	uploadSem   *semaphore.Weighted // nil when uploads are unlimited
+	uploadStats uploadMetrics
+	ids         idgen.Generator
+	storageKey  *StorageKeyTemplate
+	reindex     reindexJob
+	breaker     circuitBreaker // guards RegisterFile, see RegisterRetry
+	uploads     uploadDedup    // recent upload fingerprints, see DedupWindow
+	quotaHeld   quotaReservations
 }
 
 // NewHandler creates a new REST handler. uploadDir is where files are stored on disk.
@@ -40,64 +205,325 @@ func NewHandler(
 	repo repository.Repository,
 	pool *worker.Pool,
 	uploadDir string,
-	db *sql.DB,
 	logger *slog.Logger,
+	cfg Config,
 ) *Handler {
-	return &Handler{
-		grpc:      grpcSrv,
-		repo:      repo,
-		pool:      pool,
-		uploadDir: uploadDir,
-		db:        db,
-		logger:    logger,
+	h := &Handler{
+		grpc:       grpcSrv,
+		repo:       repo,
+		pool:       pool,
+		uploadDir:  uploadDir,
+		logger:     logger,
+		cfg:        cfg,
+		ids:        cfg.IDGenerator,
+		storageKey: cfg.StorageKey,
+	}
+	if h.ids == nil {
+		h.ids = idgen.UUIDv4{}
+	}
+	if h.storageKey == nil {
+		h.storageKey, _ = ParseStorageKeyTemplate(DefaultStorageKey)
+	}
+	if cfg.MaxConcurrentUploads > 0 {
+		h.uploadSem = semaphore.NewWeighted(cfg.MaxConcurrentUploads)
 	}
+	return h
 }
 
 // RegisterRoutes attaches all REST routes to the given mux.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /files", h.uploadFile)
+	mux.HandleFunc("POST /files/stage", h.stageFile)
+	mux.HandleFunc("POST /files/batch-get", h.batchGetFiles)
+	mux.HandleFunc("POST /hash", h.hashBody)
+	mux.HandleFunc("GET /files/export.csv", h.exportCSV)
 	mux.HandleFunc("GET /files/{id}", h.getFile)
+	mux.HandleFunc("GET /files/{id}/content", h.downloadFile)
+	mux.HandleFunc("GET /files/{id}/digest", h.getDigest)
+	mux.HandleFunc("GET /files/{id}/log", h.getFileLog)
+	mux.HandleFunc("GET /files/{id}/items", h.getFileItems)
+	mux.HandleFunc("DELETE /files/{id}", h.deleteFile)
+	mux.HandleFunc("PATCH /files/{id}/status", h.updateStatus)
 	mux.HandleFunc("GET /files", h.listFiles)
 	mux.HandleFunc("GET /healthz", h.healthz)
+	mux.HandleFunc("GET /metadata-schema", h.metadataSchema)
+	mux.HandleFunc("GET /quota", h.getQuota)
+	mux.HandleFunc("GET /stats/ingest", h.ingestStats)
+	mux.HandleFunc("GET /admin/pool", h.requireAdmin(h.poolStats))
+	mux.HandleFunc("POST /admin/pool/reset", h.requireAdmin(h.resetPoolStats))
+	mux.HandleFunc("POST /admin/pause", h.requireAdmin(h.pausePool))
+	mux.HandleFunc("POST /admin/resume", h.requireAdmin(h.resumePool))
+	mux.HandleFunc("GET /admin/metrics", h.requireAdmin(h.metricsHandler))
+	mux.HandleFunc("GET /admin/settings", h.requireAdmin(h.listSettings))
+	mux.HandleFunc("GET /admin/settings/{key}", h.requireAdmin(h.getSetting))
+	mux.HandleFunc("PUT /admin/settings/{key}", h.requireAdmin(h.putSetting))
+	mux.HandleFunc("POST /admin/purge", h.requireAdmin(h.purgeFiles))
+	mux.HandleFunc("POST /admin/reindex", h.requireAdmin(h.startReindex))
+	mux.HandleFunc("GET /admin/reindex", h.requireAdmin(h.getReindexStatus))
+
+	// Unmatched paths under the API prefixes get a JSON 404 instead of
+	// falling through to the dashboard's file server.
+	for _, prefix := range apiPrefixes {
+		mux.HandleFunc("GET "+prefix, apiNotFound)
+	}
 
 	// Serve the frontend dashboard.
-	mux.Handle("/", http.FileServer(http.Dir("web")))
+	h.registerStatic(mux)
+}
+
+// apiPrefixes are the path trees owned by the API. The file server never
+// answers below them.
+var apiPrefixes = []string{"/files/", "/admin/", "/stats/"}
+
+// apiNotFound answers API-looking paths that no route matches.
+func apiNotFound(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusNotFound, "not_found", "no such endpoint: "+r.URL.Path)
+}
+
+// registerStatic mounts the dashboard at "/": Config.StaticDir when it exists
+// on disk, otherwise the embedded Config.StaticFS. It is mounted for GET (and
+// so HEAD) only: a method-less catch-all would also match API paths called
+// with the wrong method and turn the mux's 405 and Allow header into a 404.
+func (h *Handler) registerStatic(mux *http.ServeMux) {
+	if dir := h.cfg.StaticDir; dir != "" {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			h.logger.Info("serving dashboard from disk", slog.String("dir", dir))
+			mux.Handle("GET /", http.FileServer(http.Dir(dir)))
+			return
+		}
+		h.logger.Warn("static directory not found, falling back to embedded dashboard", slog.String("dir", dir))
+	}
+
+	if h.cfg.StaticFS == nil {
+		h.logger.Info("static file serving disabled")
+		return
+	}
+	mux.Handle("GET /", http.FileServer(http.FS(h.cfg.StaticFS)))
+}
+
+// uploadFormFile opens the upload's file part from the parsed form: the one
+// under field, or with Config.AnyUploadField the first file part by name.
+func (h *Handler) uploadFormFile(r *http.Request, field string) (multipart.File, *multipart.FileHeader, error) {
+	file, header, err := r.FormFile(field)
+	if !errors.Is(err, http.ErrMissingFile) || !h.cfg.AnyUploadField || r.MultipartForm == nil {
+		return file, header, err
+	}
+	names := make([]string, 0, len(r.MultipartForm.File))
+	for name, headers := range r.MultipartForm.File {
+		if len(headers) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, err
+	}
+	sort.Strings(names)
+	header = r.MultipartForm.File[names[0]][0]
+	file, err = header.Open()
+	return file, header, err
 }
 
 // ---------- POST /files ----------
 
 func (h *Handler) uploadFile(w http.ResponseWriter, r *http.Request) {
-	// Generate a request_id for structured logging (rubric: consistent attributes).
-	requestID := uuid.New().String()
-	logger := h.logger.With(slog.String("request_id", requestID))
+	// The request_id matches the access-log line for this request.
+	requestID := requestIDFrom(r)
+	owner := clientID(r)
+	logger := h.logger.With(slog.String("request_id", requestID), slog.String("owner", owner))
 
 	logger.Info("upload request received")
 
-	// Limit upload body to 32 MB.
-	r.Body = http.MaxBytesReader(w, r.Body, 32<<20)
+	// Reject oversized bodies up front when the client declares its size, before
+	// taking an upload slot or reading anything. Chunked requests (ContentLength
+	// -1) are still capped by MaxBytesReader below.
+	if r.ContentLength > maxUploadBytes {
+		logger.Warn("upload rejected, declared size too large", slog.Int64("content_length", r.ContentLength))
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+			"request body exceeds the upload size limit")
+		return
+	}
+	if h.cfg.DiskGuard.Throttled() {
+		logger.Warn("upload rejected, disk above high-water mark")
+		writeAPIError(w, http.StatusInsufficientStorage, "insufficient_storage",
+			"upload storage is nearly full, try again later")
+		return
+	}
+
+	// Give the upload its own deadline in place of the server-wide timeouts.
+	// MaxBytesReader below still caps the size; this caps the time.
+	if h.cfg.UploadTimeout > 0 {
+		deadline := time.Now().Add(h.cfg.UploadTimeout)
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(deadline); err != nil {
+			logger.Warn("set upload read deadline", slog.String("error", err.Error()))
+		}
+		if err := rc.SetWriteDeadline(deadline); err != nil {
+			logger.Warn("set upload write deadline", slog.String("error", err.Error()))
+		}
+	}
+
+	// While the file registry is down, refuse before reading the body: the
+	// upload could not be registered anyway.
+	if h.breaker.rejecting(h.cfg.RegisterRetry, time.Now()) {
+		h.writeBreakerOpen(w, logger)
+		return
+	}
+
+	// Bound the number of uploads streamed concurrently so a burst of large
+	// files cannot exhaust memory or disk bandwidth.
+	if h.uploadSem != nil {
+		waitCtx, cancel := context.WithTimeout(r.Context(), h.cfg.UploadSlotWait)
+		err := h.uploadSem.Acquire(waitCtx, 1)
+		cancel()
+		if err != nil {
+			logger.Warn("upload rejected, too many concurrent uploads", slog.String("error", err.Error()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(h.cfg.UploadSlotWait)))
+			http.Error(w, "too many concurrent uploads", http.StatusServiceUnavailable)
+			return
+		}
+		defer h.uploadSem.Release(1)
+	}
+
+	// Limit upload body size. This is the only bound on chunked bodies:
+	// ParseMultipartForm below reads the whole body, so an overflow surfaces
+	// there as *http.MaxBytesError (413) mid-stream. The multipart package
+	// removes its own spill files on error, and our temp file is created
+	// only after parsing, so nothing is left behind in uploadDir.
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+
+	// Parse the form explicitly so the memory threshold is ours, then bound
+	// each text field: a tiny file must not carry a 30 MB "options" field.
+	memory := h.cfg.MultipartMaxMemory
+	if memory <= 0 {
+		memory = defaultMultipartMemory
+	}
+	field := h.cfg.UploadField
+	if field == "" {
+		field = defaultUploadField
+	}
+	if err := r.ParseMultipartForm(memory); err != nil {
+		status, code, msg := classifyFormFileError(r, field, err)
+		logger.Error("parse multipart form", slog.String("code", code), slog.String("error", err.Error()))
+		writeAPIError(w, status, code, msg)
+		return
+	}
+	fieldLimit := h.cfg.MaxFormFieldBytes
+	if fieldLimit <= 0 {
+		fieldLimit = defaultMaxFormField
+	}
+	if name, ok := oversizedFormField(r.MultipartForm, fieldLimit); ok {
+		logger.Warn("upload rejected, form field too large", slog.String("field", name))
+		writeAPIError(w, http.StatusBadRequest, "field_too_large",
+			fmt.Sprintf("form field %q exceeds %d bytes", name, fieldLimit))
+		return
+	}
 
-	file, header, err := r.FormFile("file")
+	file, header, err := h.uploadFormFile(r, field)
 	if err != nil {
-		logger.Error("form file error", slog.String("error", err.Error()))
-		http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		status, code, msg := classifyFormFileError(r, field, err)
+		logger.Error("form file error", slog.String("code", code), slog.String("error", err.Error()))
+		writeAPIError(w, status, code, msg)
 		return
 	}
 	defer file.Close()
 
-	// ---- Generate unique filename using google/uuid ----
+	// ---- Enforce the client's storage quota before touching disk ----
+	quota, err := h.quotaFor(r.Context(), owner)
+	if err != nil {
+		logger.Error("load quota", slog.String("error", err.Error()))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// The reservation covers the upload until it returns; by then the
+	// record, if any, is counted by the usage query.
+	if !h.quotaHeld.reserve(quota, header.Size) {
+		logger.Warn("upload rejected, quota exceeded",
+			slog.Int64("used_bytes", quota.UsedBytes),
+			slog.Int64("limit_bytes", quota.LimitBytes),
+			slog.Int64("upload_bytes", header.Size),
+		)
+		http.Error(w, "storage quota exceeded", http.StatusRequestEntityTooLarge)
+		return
+	}
+	defer h.quotaHeld.release(quota, header.Size)
+
+	// ---- Optional per-upload processing options ----
+	extractors, err := parseProcessingOptions(r.FormValue("options"), logger)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_options", err.Error())
+		return
+	}
+
+	// ---- Optional client metadata, merged with the computed keys ----
+	clientMeta, err := h.parseClientMetadata(r.FormValue("metadata"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_metadata", err.Error())
+		return
+	}
+	var metadataJSON string
+	if clientMeta != nil {
+		b, _ := json.Marshal(clientMeta) // decoded from JSON, so it re-encodes
+		metadataJSON = string(b)
+	}
+
+	// ---- Retention: optional "ttl" form field overrides the default ----
+	ttl := h.cfg.DefaultTTL
+	if v := r.FormValue("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid_ttl", "ttl must be a positive duration such as \"24h\"")
+			return
+		}
+		ttl = d
+	}
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+
+	// ---- Generate unique ID from the configured ID generator ----
 	// Preserve the original file extension for metadata extraction.
 	origExt := filepath.Ext(header.Filename) // e.g. ".pdf", ".txt", ".png"
-	fileID := uuid.New().String()
-	safeFilename := fileID + origExt // e.g. "550e8400-e29b-...pdf"
+	fileID := h.ids.NewID()
 
-	// ---- Prevent directory traversal attacks ----
-	destPath := filepath.Join(h.uploadDir, safeFilename)
-	destPath = filepath.Clean(destPath)
-	if !strings.HasPrefix(destPath, filepath.Clean(h.uploadDir)+string(os.PathSeparator)) {
-		logger.Error("directory traversal attempt", slog.String("path", destPath))
-		http.Error(w, "invalid file path", http.StatusBadRequest)
+	// ---- Answer a likely double-submit with the first upload's ID ----
+	uploaded := false
+	if h.cfg.DedupWindow > 0 {
+		key := uploadFingerprint(owner, header.Filename, header.Size)
+		if firstID := h.uploads.claim(key, fileID, h.cfg.DedupWindow, time.Now()); firstID != "" {
+			logger.Info("duplicate upload within dedup window",
+				slog.String("file_id", firstID),
+				slog.String("original_name", header.Filename),
+				slog.Int64("size", header.Size),
+			)
+			w.Header().Set("Location", "/files/"+firstID)
+			writeJSON(w, r, http.StatusOK, map[string]interface{}{
+				"id":        firstID,
+				"duplicate": true,
+			})
+			return
+		}
+		defer func() {
+			if !uploaded {
+				h.uploads.release(key, fileID)
+			}
+		}()
+	}
+
+	// ---- Hold a file-handle slot while the temp file is open ----
+	if err := h.cfg.FileLimiter.Acquire(r.Context(), 1); err != nil {
+		logger.Warn("gave up waiting for a file handle", slog.String("error", err.Error()))
+		http.Error(w, "too many open files", http.StatusServiceUnavailable)
 		return
 	}
+	fdHeld := true
+	releaseFD := func() {
+		if fdHeld {
+			fdHeld = false
+			h.cfg.FileLimiter.Release(1)
+		}
+	}
+	defer releaseFD()
 
 	// ---- Atomic write: temp file → rename ----
 	tmpFile, err := os.CreateTemp(h.uploadDir, "upload-*.tmp")
@@ -112,7 +538,16 @@ func (h *Handler) uploadFile(w http.ResponseWriter, r *http.Request) {
 	bw := bufio.NewWriter(tmpFile)
 
 	// Stream the upload using io.Copy — never loads the whole file into memory.
-	if _, err := io.Copy(bw, file); err != nil {
+	// A storage key with {hash} needs the digest, taken in the same pass.
+	var src io.Reader = file
+	var digest hash.Hash
+	if h.storageKey.NeedsHash() {
+		digest = sha256.New()
+		src = io.TeeReader(file, digest)
+	}
+	copyStart := time.Now()
+	written, err := io.Copy(bw, src)
+	if err != nil {
 		tmpFile.Close()
 		os.Remove(tmpPath)
 		logger.Error("stream to disk", slog.String("error", err.Error()))
@@ -127,15 +562,43 @@ func (h *Handler) uploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	tmpFile.Close()
+	releaseFD()
+	copyDur := time.Since(copyStart)
 
-	// Atomic rename from temp file to final destination.
-	if err := os.Rename(tmpPath, destPath); err != nil {
+	// ---- Resolve the storage key, refusing directory traversal ----
+	var contentHash string
+	if digest != nil {
+		contentHash = hex.EncodeToString(digest.Sum(nil))
+	}
+	destPath, err := h.storagePath(fileID, origExt, contentHash, copyStart)
+	if err != nil {
 		os.Remove(tmpPath)
-		logger.Error("atomic rename", slog.String("error", err.Error()))
+		if errors.Is(err, errPathTraversal) {
+			logger.Error("directory traversal attempt", slog.String("file_id", fileID), slog.String("ext", origExt))
+			http.Error(w, "invalid file path", http.StatusBadRequest)
+			return
+		}
+		logger.Error("create storage directory", slog.String("error", err.Error()))
 		http.Error(w, "failed to save file", http.StatusInternalServerError)
 		return
 	}
 
+	// Atomic move from temp file to final destination, honouring the
+	// configured collision strategy.
+	skipped, err := placeFile(tmpPath, destPath, h.cfg.CollisionStrategy)
+	if err != nil {
+		logger.Error("place file", slog.String("path", destPath), slog.String("error", err.Error()))
+		if errors.Is(err, errDestExists) {
+			http.Error(w, "destination file already exists", http.StatusConflict)
+		} else {
+			http.Error(w, "failed to save file", http.StatusInternalServerError)
+		}
+		return
+	}
+	if skipped {
+		logger.Info("destination exists, keeping existing file", slog.String("path", destPath))
+	}
+
 	logger.Info("file saved to disk",
 		slog.String("file_id", fileID),
 		slog.String("path", destPath),
@@ -143,45 +606,112 @@ func (h *Handler) uploadFile(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// ---- Register in DB via gRPC service ----
-	_, err = h.grpc.RegisterFile(r.Context(), &pb.RegisterFileRequest{
-		Id:       fileID,
-		FilePath: destPath,
-		Status:   "pending",
+	err = h.registerFile(r.Context(), logger, &pb.RegisterFileRequest{
+		Id:           fileID,
+		FilePath:     destPath,
+		Status:       repository.StatusPending,
+		Owner:        owner,
+		Size:         written,
+		ExpiresAt:    expiresAt,
+		OriginalName: filepath.Base(header.Filename),
+		MetadataJson: metadataJSON,
 	})
+	if errors.Is(err, errBreakerOpen) {
+		// The breaker opened while this upload was being received.
+		if !skipped {
+			os.Remove(destPath)
+		}
+		h.writeBreakerOpen(w, logger)
+		return
+	}
 	if err != nil {
 		logger.Error("grpc RegisterFile", slog.String("error", err.Error()))
 		// Map gRPC error codes to HTTP status codes (rubric requirement).
 		httpCode := grpcToHTTPStatus(err)
+		if httpCode == http.StatusConflict {
+			writeAPIError(w, httpCode, "file_exists", status.Convert(err).Message())
+			return
+		}
+		if status.Code(err) == codes.InvalidArgument {
+			// Rejected by the repository's content policy.
+			if !skipped {
+				os.Remove(destPath)
+			}
+			writeAPIError(w, httpCode, "policy_violation", status.Convert(err).Message())
+			return
+		}
 		http.Error(w, "failed to register file", httpCode)
 		return
 	}
 
-	// ---- Submit processing job to worker pool ----
-	// Use context.Background() because this is a background task that outlives the HTTP request.
-	// The pool's own context handles shutdown cancellation.
-	h.pool.Submit(worker.Job{
-		Ctx:      context.Background(),
-		FileID:   fileID,
-		FilePath: destPath,
-	})
+	h.cfg.Events.Append(fileID, "uploaded", fmt.Sprintf("%d bytes", written))
+
+	// ---- Submit processing job to the durable queue or worker pool ----
+	if h.cfg.JobQueue != nil {
+		if err := h.cfg.JobQueue.Enqueue(r.Context(), &repository.QueuedJob{
+			FileID:     fileID,
+			FilePath:   destPath,
+			Extractors: extractors,
+		}); err != nil {
+			// The file is stored but no job exists; the record stays pending.
+			logger.Error("enqueue job", slog.String("file_id", fileID), slog.String("error", err.Error()))
+			http.Error(w, "failed to queue file for processing", http.StatusInternalServerError)
+			return
+		}
+	} else if err := h.submitJob(r.Context(), worker.Job{
+		// Use context.Background() because this is a background task that outlives the HTTP request.
+		// The pool's own context handles shutdown cancellation.
+		Ctx:        context.Background(),
+		FileID:     fileID,
+		FilePath:   destPath,
+		Extractors: extractors,
+	}); err != nil {
+		switch {
+		case errors.Is(err, errQueueFull):
+			// Undo the upload so the client can simply retry it.
+			logger.Warn("processing queue full, upload rejected", slog.String("file_id", fileID))
+			if err := h.repo.Delete(r.Context(), fileID); err != nil {
+				logger.Error("delete rejected upload", slog.String("file_id", fileID), slog.String("error", err.Error()))
+			}
+			if !skipped {
+				os.Remove(destPath)
+			}
+			w.Header().Set("Retry-After", "1")
+			writeAPIError(w, http.StatusServiceUnavailable, "queue_full", "processing queue is full, retry later")
+		case errors.Is(err, errPoolClosed):
+			// Only happens once shutdown has begun; the record stays pending.
+			logger.Warn("worker pool closed, processing not submitted", slog.String("file_id", fileID))
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		default:
+			// The file is stored but no job exists; the record stays pending.
+			logger.Error("spill job", slog.String("file_id", fileID), slog.String("error", err.Error()))
+			http.Error(w, "failed to queue file for processing", http.StatusInternalServerError)
+		}
+		return
+	}
 
+	h.uploadStats.observe(written, copyDur)
 	logger.Info("file upload complete, processing submitted",
 		slog.String("file_id", fileID),
+		slog.Int64("bytes", written),
+		slog.Duration("write_duration", copyDur),
+		slog.Float64("mb_per_sec", mbPerSec(written, copyDur)),
 	)
 
+	uploaded = true
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Location", "/files/"+fileID)
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]string{
 		"id":     fileID,
-		"status": "pending",
+		"status": repository.StatusPending,
 	})
 }
 
 // ---------- GET /files/{id} ----------
 
 func (h *Handler) getFile(w http.ResponseWriter, r *http.Request) {
-	requestID := uuid.New().String()
+	requestID := requestIDFrom(r)
 	logger := h.logger.With(slog.String("request_id", requestID))
 
 	id := r.PathValue("id")
@@ -192,6 +722,17 @@ func (h *Handler) getFile(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info("get file request", slog.String("file_id", id))
 
+	contentType := negotiate(r.Header.Get("Accept"), []string{mimeJSON, mimeCSV, mimeXML})
+	if contentType == "" {
+		http.Error(w, "not acceptable: supported types are application/json, text/csv, application/xml", http.StatusNotAcceptable)
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "unknown_field", err.Error())
+		return
+	}
+
 	rec, err := h.repo.GetByID(r.Context(), id)
 	if err != nil {
 		logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
@@ -204,27 +745,112 @@ func (h *Handler) getFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":        rec.ID,
-		"hash":       rec.Hash,
-		"size":       rec.Size,
-		"status":     rec.Status,
-		"file_path":  rec.FilePath,
-		"created_at": rec.CreatedAt,
-		"metadata":   rec.Metadata,
-	})
+	// Report foreign files as missing so IDs owned by others are not disclosed.
+	if !h.canAccess(r, rec) {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	body := project(recordToMap(rec), fields)
+	switch contentType {
+	case mimeCSV:
+		err = writeCSV(w, []map[string]interface{}{body})
+	case mimeXML:
+		err = writeXML(w, body)
+	default:
+		err = writeJSON(w, r, http.StatusOK, body)
+	}
+	if err != nil {
+		logger.Error("encode response", slog.String("file_id", id), slog.String("error", err.Error()))
+	}
+}
+
+// ---------- DELETE /files/{id} ----------
+
+func (h *Handler) deleteFile(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	id := r.PathValue("id")
+	logger.Info("delete file request", slog.String("file_id", id))
+
+	rec, err := h.repo.GetByID(r.Context(), id)
+	if err == nil && !h.canAccess(r, rec) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "file not found", http.StatusNotFound)
+		} else {
+			logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !ifMatch(r, rec) {
+		logger.Info("delete precondition failed", slog.String("file_id", id))
+		http.Error(w, "file has changed", http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id); err != nil {
+		logger.Error("delete file", slog.String("file_id", id), slog.String("error", err.Error()))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "file not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The record is gone; a leftover file on disk is only wasted space.
+	if err := os.Remove(rec.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("remove file from disk", slog.String("file_id", id), slog.String("error", err.Error()))
+	}
+
+	logger.Info("file deleted", slog.String("file_id", id))
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // ---------- GET /files (list all) ----------
 
 func (h *Handler) listFiles(w http.ResponseWriter, r *http.Request) {
-	requestID := uuid.New().String()
+	requestID := requestIDFrom(r)
 	logger := h.logger.With(slog.String("request_id", requestID))
 
 	logger.Info("list files request")
 
-	records, err := h.repo.ListAll(r.Context())
+	fields, err := parseFields(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "unknown_field", err.Error())
+		return
+	}
+
+	dateRange, ranged, err := parseDateRange(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_range", err.Error())
+		return
+	}
+
+	owner := clientID(r)
+	if h.isAdmin(r) {
+		owner = "" // admins see every owner
+	}
+
+	var records []*repository.FileRecord
+	switch mimeType := strings.TrimSpace(r.URL.Query().Get("mime_type")); {
+	case ranged && mimeType != "":
+		writeAPIError(w, http.StatusBadRequest, "conflicting_filters", "mime_type cannot be combined with from, to or status")
+		return
+	case ranged:
+		records, err = h.repo.ListByDateRange(r.Context(), owner, dateRange.status, dateRange.from, dateRange.to, dateRange.limit, dateRange.offset)
+	case mimeType != "":
+		records, err = h.repo.ListByMimeType(r.Context(), owner, strings.ToLower(mimeType))
+	case owner == "":
+		records, err = h.repo.ListAll(r.Context())
+	default:
+		records, err = h.repo.ListByOwner(r.Context(), owner)
+	}
 	if err != nil {
 		logger.Error("list files", slog.String("error", err.Error()))
 		http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -234,19 +860,10 @@ func (h *Handler) listFiles(w http.ResponseWriter, r *http.Request) {
 	// Build JSON response.
 	result := make([]map[string]interface{}, 0, len(records))
 	for _, rec := range records {
-		result = append(result, map[string]interface{}{
-			"id":         rec.ID,
-			"hash":       rec.Hash,
-			"size":       rec.Size,
-			"status":     rec.Status,
-			"file_path":  rec.FilePath,
-			"created_at": rec.CreatedAt,
-			"metadata":   rec.Metadata,
-		})
+		result = append(result, project(recordToMap(rec), fields))
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	writeJSON(w, r, http.StatusOK, result)
 }
 
 // ---------- GET /healthz ----------
@@ -260,7 +877,7 @@ func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
 	httpStatus := http.StatusOK
 
 	// Check database connectivity.
-	if err := h.db.PingContext(ctx); err != nil {
+	if err := h.repo.Ping(ctx); err != nil {
 		result["status"] = "degraded"
 		result["database"] = "unreachable: " + err.Error()
 		httpStatus = http.StatusServiceUnavailable
@@ -277,9 +894,107 @@ func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
 		result["disk"] = "ok"
 	}
 
+	// Report descriptor pressure; waiting callers mean the limit is saturated.
+	if fd := h.cfg.FileLimiter.Stats(); fd.Max > 0 {
+		result["file_handles"] = fmt.Sprintf("%d/%d in use, %d waiting", fd.InUse, fd.Max, fd.Waiting)
+	}
+
+	// A throttled disk still serves reads, so it does not degrade health.
+	if ds, ok := h.cfg.DiskGuard.Stats(); ok {
+		usage := fmt.Sprintf("%.1f%% used", ds.UsedPercent)
+		if ds.Throttled {
+			usage += ", uploads throttled"
+		}
+		result["disk_usage"] = usage
+	}
+
+	if h.cfg.IsLeader != nil {
+		result["leader"] = strconv.FormatBool(h.cfg.IsLeader())
+	}
+
+	writeJSON(w, r, httpStatus, result)
+}
+
+// oversizedFormField returns the name of the first non-file field, in name
+// order, with a value longer than limit bytes.
+func oversizedFormField(form *multipart.Form, limit int64) (string, bool) {
+	if form == nil {
+		return "", false
+	}
+	names := make([]string, 0, len(form.Value))
+	for name := range form.Value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range form.Value[name] {
+			if int64(len(v)) > limit {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseProcessingOptions decodes the "options" form field, a JSON object of
+// extractor name to enabled flag, e.g. {"image": false}. Unknown names are
+// ignored with a warning so older servers accept newer clients. An empty
+// field yields nil (all extractors enabled, nothing recorded).
+func parseProcessingOptions(raw string, logger *slog.Logger) (map[string]bool, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var opts map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+		return nil, errors.New("options must be a JSON object")
+	}
+
+	extractors := make(map[string]bool, len(opts))
+	for name, v := range opts {
+		if !hasher.IsExtractor(name) {
+			logger.Warn("ignoring unknown processing option", slog.String("option", name))
+			continue
+		}
+		var on bool
+		if err := json.Unmarshal(v, &on); err != nil {
+			return nil, fmt.Errorf("option %q must be a boolean", name)
+		}
+		extractors[name] = on
+	}
+	return extractors, nil
+}
+
+// writeJSON encodes v as a JSON response with the given status. Output is
+// compact unless the caller asks for ?pretty=true or sends "X-Pretty: true".
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(httpStatus)
-	json.NewEncoder(w).Encode(result)
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	if wantsPretty(r) {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
+
+// wantsPretty reports whether the caller requested indented JSON.
+func wantsPretty(r *http.Request) bool {
+	v := r.URL.Query().Get("pretty")
+	if v == "" {
+		v = r.Header.Get("X-Pretty")
+	}
+	pretty, _ := strconv.ParseBool(v)
+	return pretty
+}
+
+// retryAfterSeconds converts a wait duration to a whole-second Retry-After value (minimum 1).
+func retryAfterSeconds(d time.Duration) int {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		return 1
+	}
+	return secs
 }
 
 // grpcToHTTPStatus maps gRPC status codes to HTTP status codes (rubric requirement).
@@ -295,6 +1010,8 @@ func grpcToHTTPStatus(err error) int {
 		return http.StatusConflict
 	case codes.InvalidArgument:
 		return http.StatusBadRequest
+	case codes.FailedPrecondition:
+		return http.StatusConflict
 	case codes.DeadlineExceeded:
 		return http.StatusGatewayTimeout
 	case codes.Unauthenticated:
@@ -0,0 +1,34 @@
+package restapi
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/mtiwari1/gopherdrive/internal/hasher"
+)
+
+// ---------- POST /hash ----------
+
+// hashBody streams the raw request body through SHA256 and returns
+// {hash, size, mime_type} without writing to disk or the database.
+func (h *Handler) hashBody(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	body := http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	digest, err := hasher.HashReader(body)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "request_too_large", "request body exceeds the upload size limit")
+			return
+		}
+		logger.Error("hash body", slog.String("error", err.Error()))
+		writeAPIError(w, http.StatusBadRequest, "read_failed", "failed to read request body")
+		return
+	}
+
+	logger.Info("hash computed", slog.String("hash", digest.Hash), slog.Int64("size", digest.Size))
+	writeJSON(w, r, http.StatusOK, digest)
+}
@@ -0,0 +1,42 @@
+package restapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+const (
+	// clientIDHeader carries the caller identity. It is expected to be set by the
+	// authenticating proxy in front of GopherDrive, never by end users directly.
+	clientIDHeader = "X-Client-ID"
+
+	// adminTokenHeader grants an owner-scope bypass when it matches Config.AdminToken.
+	adminTokenHeader = "X-Admin-Token"
+)
+
+// clientID returns the authenticated client for the request, falling back to
+// the shared owner when running unauthenticated.
+func clientID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get(clientIDHeader)); id != "" {
+		return id
+	}
+	return repository.DefaultOwner
+}
+
+// isAdmin reports whether the request carries the configured admin token.
+// Admin access is disabled when no token is configured.
+func (h *Handler) isAdmin(r *http.Request) bool {
+	if h.cfg.AdminToken == "" {
+		return false
+	}
+	got := r.Header.Get(adminTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(h.cfg.AdminToken)) == 1
+}
+
+// canAccess reports whether the caller may read or modify rec.
+func (h *Handler) canAccess(r *http.Request, rec *repository.FileRecord) bool {
+	return h.isAdmin(r) || rec.Owner == clientID(r)
+}
@@ -0,0 +1,82 @@
+package restapi
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+const (
+	defaultIngestWindow = time.Hour
+	maxIngestWindow     = 24 * time.Hour
+)
+
+// ingestTotals aggregates the buckets of one /stats/ingest response.
+type ingestTotals struct {
+	Uploads          int64   `json:"uploads"`
+	Bytes            int64   `json:"bytes"`
+	Completed        int64   `json:"completed"`
+	Failed           int64   `json:"failed"`
+	UploadsPerMinute float64 `json:"uploads_per_minute"`
+	BytesPerMinute   float64 `json:"bytes_per_minute"`
+	SuccessRate      float64 `json:"success_rate"` // completed / (completed + failed)
+	FailureRate      float64 `json:"failure_rate"`
+}
+
+func sumIngest(buckets []repository.IngestBucket) ingestTotals {
+	var t ingestTotals
+	for _, b := range buckets {
+		t.Uploads += b.Uploads
+		t.Bytes += b.Bytes
+		t.Completed += b.Completed
+		t.Failed += b.Failed
+	}
+	if n := float64(len(buckets)); n > 0 {
+		t.UploadsPerMinute = float64(t.Uploads) / n
+		t.BytesPerMinute = float64(t.Bytes) / n
+	}
+	if finished := t.Completed + t.Failed; finished > 0 {
+		t.SuccessRate = float64(t.Completed) / float64(finished)
+		t.FailureRate = float64(t.Failed) / float64(finished)
+	}
+	return t
+}
+
+// ---------- GET /stats/ingest?window=<duration> ----------
+
+// ingestStats reports per-minute upload volume over a recent window for
+// dashboards. Clients see their own uploads; admins see everyone's.
+func (h *Handler) ingestStats(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	window := defaultIngestWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d > maxIngestWindow {
+			writeAPIError(w, http.StatusBadRequest, "invalid_window", "window must be a duration between 1m and 24h")
+			return
+		}
+		window = d
+	}
+
+	owner := clientID(r)
+	if h.isAdmin(r) {
+		owner = "" // admins see every owner
+	}
+
+	buckets, err := h.repo.IngestStats(r.Context(), owner, window)
+	if err != nil {
+		logger.Error("ingest stats", slog.String("error", err.Error()))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"window":  window.String(),
+		"totals":  sumIngest(buckets),
+		"buckets": buckets,
+	})
+}
@@ -0,0 +1,75 @@
+package restapi
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultItemsLimit = 100
+	maxItemsLimit     = 1000
+)
+
+// ---------- GET /files/{id}/items?key=&limit=&offset= ----------
+
+// getFileItems pages through the list metadata that processing moved out of
+// the record (keys shown there as {"count", "sample", "spilled": true}).
+// Without ?key= every key is listed, ordered by key then index.
+func (h *Handler) getFileItems(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	id := r.PathValue("id")
+	q := r.URL.Query()
+	key := q.Get("key")
+
+	limit, offset := defaultItemsLimit, 0
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxItemsLimit {
+			writeAPIError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and "+strconv.Itoa(maxItemsLimit))
+			return
+		}
+		limit = n
+	}
+	if s := q.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid_offset", "offset must be a non-negative integer")
+			return
+		}
+		offset = n
+	}
+
+	rec, err := h.repo.GetByID(r.Context(), id)
+	if err == nil && !h.canAccess(r, rec) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "file not found")
+		} else {
+			logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
+			writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		}
+		return
+	}
+
+	items, err := h.repo.ListMetadataItems(r.Context(), rec.ID, key, limit, offset)
+	if err != nil {
+		logger.Error("list metadata items", slog.String("file_id", id), slog.String("error", err.Error()))
+		writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"id":     rec.ID,
+		"key":    key,
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
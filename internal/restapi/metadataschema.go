@@ -0,0 +1,20 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/mtiwari1/gopherdrive/internal/hasher"
+)
+
+// ---------- GET /metadata-schema ----------
+
+// metadataSchema lists the metadata keys a processed file may carry: the
+// common ones, then per extractor with the MIME types it runs for. It is
+// read from the hasher's extractor registry, so it cannot drift from what
+// processing actually produces.
+func (h *Handler) metadataSchema(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"common":     hasher.CommonFields(),
+		"extractors": hasher.Extractors(),
+	})
+}
@@ -0,0 +1,93 @@
+package restapi
+
+import (
+	"database/sql"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// uploadMetrics accumulates disk-write throughput across successful uploads.
+type uploadMetrics struct {
+	uploads    atomic.Int64
+	bytes      atomic.Int64
+	writeNanos atomic.Int64
+}
+
+// observe records one upload's byte count and io.Copy duration.
+func (m *uploadMetrics) observe(n int64, d time.Duration) {
+	m.uploads.Add(1)
+	m.bytes.Add(n)
+	m.writeNanos.Add(int64(d))
+}
+
+// uploadMetricsSnapshot is the JSON form of uploadMetrics.
+type uploadMetricsSnapshot struct {
+	Uploads      int64   `json:"uploads"`
+	Bytes        int64   `json:"bytes"`
+	WriteSeconds float64 `json:"write_seconds"`
+	AvgMBps      float64 `json:"avg_mb_per_sec"`
+}
+
+func (m *uploadMetrics) snapshot() uploadMetricsSnapshot {
+	s := uploadMetricsSnapshot{
+		Uploads:      m.uploads.Load(),
+		Bytes:        m.bytes.Load(),
+		WriteSeconds: time.Duration(m.writeNanos.Load()).Seconds(),
+	}
+	s.AvgMBps = mbPerSec(s.Bytes, time.Duration(m.writeNanos.Load()))
+	return s
+}
+
+// mbPerSec converts a byte count and duration to MB/s (10^6 bytes), 0 if d is 0.
+func mbPerSec(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / 1e6 / d.Seconds()
+}
+
+// dbPoolSnapshot is the JSON form of sql.DBStats.
+type dbPoolSnapshot struct {
+	MaxOpen           int     `json:"max_open"`
+	Open              int     `json:"open"`
+	InUse             int     `json:"in_use"`
+	Idle              int     `json:"idle"`
+	WaitCount         int64   `json:"wait_count"`
+	WaitSeconds       float64 `json:"wait_seconds"`
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
+
+func dbPool(s sql.DBStats) dbPoolSnapshot {
+	return dbPoolSnapshot{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitSeconds:       s.WaitDuration.Seconds(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+}
+
+// ---------- GET /admin/metrics ----------
+
+func (h *Handler) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{
+		"uploads":            h.uploadStats.snapshot(),
+		"processing_latency": h.pool.LatencyHistogram(),
+		"file_handles":       h.cfg.FileLimiter.Stats(),
+		"mime_volume":        h.cfg.MimeVolume.Snapshot(),
+		"register_breaker":   h.breaker.snapshot(h.cfg.RegisterRetry, time.Now()),
+	}
+	// Sampled per request: sql.DB keeps these counters itself, so there is
+	// nothing to collect in between.
+	if h.cfg.DBStats != nil {
+		body["db_pool"] = dbPool(h.cfg.DBStats())
+	}
+	writeJSON(w, r, http.StatusOK, body)
+}
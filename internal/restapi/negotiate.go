@@ -0,0 +1,160 @@
+package restapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+const (
+	mimeJSON = "application/json"
+	mimeCSV  = "text/csv"
+	mimeXML  = "application/xml"
+)
+
+// recordFields fixes the field order for the flat (CSV/XML) representations.
+var recordFields = []string{"id", "seq", "hash", "size", "status", "file_path", "created_at", "metadata"}
+
+// recordToMap converts a FileRecord to its API representation.
+func recordToMap(rec *repository.FileRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             rec.ID,
+		"seq":            rec.Seq,
+		"hash":           rec.Hash,
+		"size":           rec.Size,
+		"status":         rec.Status,
+		"failure_reason": rec.FailureReason,
+		"file_path":      rec.FilePath,
+		"created_at":     rec.CreatedAt,
+		"metadata":       rec.Metadata,
+	}
+}
+
+// negotiate picks the best of offers for the Accept header, honouring q-values
+// and wildcards. An empty header selects the first offer. It returns "" when
+// nothing acceptable is offered.
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type candidate struct {
+		mediaRange string
+		q          float64
+	}
+	var ranges []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, candidate{strings.ToLower(strings.TrimSpace(mediaRange)), q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, c := range ranges {
+		for _, offer := range offers {
+			if mediaMatches(c.mediaRange, offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// mediaMatches reports whether offer falls within mediaRange ("*/*", "text/*", or exact).
+func mediaMatches(mediaRange, offer string) bool {
+	if mediaRange == "*/*" || mediaRange == offer {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok {
+		return strings.HasPrefix(offer, prefix+"/")
+	}
+	return false
+}
+
+// flatValue renders a field value as a single string for CSV/XML output.
+func flatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339)
+	case map[string]interface{}:
+		if t == nil {
+			return ""
+		}
+		b, _ := json.Marshal(t)
+		return string(b)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// writeCSV writes a header row followed by one row per record. Columns are
+// those the first record has, so a ?fields= projection narrows the table.
+func writeCSV(w http.ResponseWriter, records []map[string]interface{}) error {
+	cols := recordFields
+	if len(records) > 0 {
+		cols = presentFields(records[0])
+	}
+	w.Header().Set("Content-Type", mimeCSV)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	row := make([]string, len(cols))
+	for _, rec := range records {
+		for i, f := range cols {
+			row[i] = flatValue(rec[f])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// xmlField is one <field_name>value</field_name> element.
+type xmlField struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// xmlFile is the <file> element wrapping a record's fields.
+type xmlFile struct {
+	XMLName xml.Name `xml:"file"`
+	Fields  []xmlField
+}
+
+// writeXML writes a single record as a <file> document.
+func writeXML(w http.ResponseWriter, rec map[string]interface{}) error {
+	doc := xmlFile{}
+	for _, f := range presentFields(rec) {
+		doc.Fields = append(doc.Fields, xmlField{XMLName: xml.Name{Local: f}, Value: flatValue(rec[f])})
+	}
+	w.Header().Set("Content-Type", mimeXML)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,70 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+	"github.com/mtiwari1/gopherdrive/internal/worker"
+)
+
+// OverflowPolicy decides what an upload does when the worker pool's queue
+// is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for room, holding the request open (the default).
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowReject fails the upload with 503 so the client can retry.
+	OverflowReject OverflowPolicy = "reject"
+	// OverflowSpill parks the job in Config.SpillQueue for a feeder to
+	// submit once the pool has room.
+	OverflowSpill OverflowPolicy = "spill-to-db"
+)
+
+// ParseOverflowPolicy validates a policy name. Empty selects OverflowBlock.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case "":
+		return OverflowBlock, nil
+	case OverflowBlock, OverflowReject, OverflowSpill:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy %q (want block, reject or spill-to-db)", s)
+	}
+}
+
+var (
+	errQueueFull  = errors.New("processing queue is full")
+	errPoolClosed = errors.New("worker pool closed")
+)
+
+// submitJob hands job to the pool according to Config.OverflowPolicy.
+func (h *Handler) submitJob(ctx context.Context, job worker.Job) error {
+	switch h.cfg.OverflowPolicy {
+	case OverflowReject:
+		if !h.pool.TrySubmit(job) {
+			return errQueueFull
+		}
+	case OverflowSpill:
+		if h.pool.TrySubmit(job) {
+			return nil
+		}
+		if h.cfg.SpillQueue == nil {
+			return errQueueFull
+		}
+		// A pool that is shutting down also spills: the job survives the
+		// restart in the jobs table.
+		return h.cfg.SpillQueue.Enqueue(ctx, &repository.QueuedJob{
+			FileID:     job.FileID,
+			FilePath:   job.FilePath,
+			Extractors: job.Extractors,
+		})
+	default:
+		if !h.pool.Submit(job) {
+			return errPoolClosed
+		}
+	}
+	return nil
+}
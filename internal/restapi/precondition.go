@@ -0,0 +1,29 @@
+package restapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// ifMatch evaluates the request's If-Match header against rec's ETag (its
+// content hash, as served by downloadFile). A missing header always matches;
+// "*" matches any existing record. Comparison is strong, so weak tags never
+// match, and a file still being processed has no ETag to match.
+func ifMatch(r *http.Request, rec *repository.FileRecord) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if rec.Hash != "" && tag == `"`+rec.Hash+`"` {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package restapi
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// ---------- POST /admin/purge?before=<rfc3339>[&dry_run=true] ----------
+
+// purgeFiles deletes every record created before the cutoff, batch by batch,
+// then removes each purged file from disk. A file that cannot be removed is
+// logged and counted; its record is already gone either way. With dry_run
+// it only lists what would be purged.
+func (h *Handler) purgeFiles(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	cutoff, err := time.Parse(time.RFC3339, r.URL.Query().Get("before"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_before", "before must be an RFC 3339 timestamp")
+		return
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid_dry_run", "dry_run must be a boolean")
+			return
+		}
+	}
+	logger.Info("purge request", slog.Time("before", cutoff), slog.Bool("dry_run", dryRun))
+	if dryRun {
+		h.previewPurge(w, r, logger, cutoff)
+		return
+	}
+
+	ids := []string{}
+	diskErrors := 0
+	for {
+		records, err := h.repo.PurgeOlderThan(r.Context(), cutoff)
+		if err != nil {
+			// Earlier batches stay purged; report how far we got.
+			logger.Error("purge records", slog.Int("purged", len(ids)), slog.String("error", err.Error()))
+			writeAPIError(w, http.StatusInternalServerError, "internal", "purge stopped after an error")
+			return
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, rec := range records {
+			ids = append(ids, rec.ID)
+			if err := os.Remove(rec.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				diskErrors++
+				logger.Warn("remove purged file", slog.String("file_id", rec.ID), slog.String("error", err.Error()))
+			}
+		}
+	}
+
+	logger.Info("purge finished", slog.Int("purged", len(ids)), slog.Int("disk_errors", diskErrors))
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"purged":      len(ids),
+		"ids":         ids,
+		"disk_errors": diskErrors,
+	})
+}
+
+// previewPurge reports the records a purge with the same cutoff would
+// delete. Nothing is locked, so the real purge may differ if records are
+// added or removed in between.
+func (h *Handler) previewPurge(w http.ResponseWriter, r *http.Request, logger *slog.Logger, cutoff time.Time) {
+	ids := []string{}
+	afterID := ""
+	for {
+		records, err := h.repo.ListCreatedBefore(r.Context(), cutoff, afterID, repository.PurgeBatch)
+		if err != nil {
+			logger.Error("preview purge", slog.String("error", err.Error()))
+			writeAPIError(w, http.StatusInternalServerError, "internal", "failed to list records")
+			return
+		}
+		for _, rec := range records {
+			ids = append(ids, rec.ID)
+		}
+		if len(records) < repository.PurgeBatch {
+			break
+		}
+		afterID = records[len(records)-1].ID
+	}
+
+	logger.Info("purge dry run finished", slog.Int("would_purge", len(ids)))
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"dry_run":     true,
+		"would_purge": len(ids),
+		"ids":         ids,
+	})
+}
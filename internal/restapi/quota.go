@@ -0,0 +1,112 @@
+package restapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/mtiwari1/gopherdrive/internal/settings"
+)
+
+// Quota describes a client's storage allowance and current usage.
+type Quota struct {
+	Owner      string `json:"owner"`
+	UsedBytes  int64  `json:"used_bytes"`
+	LimitBytes int64  `json:"limit_bytes"` // 0 means unlimited
+}
+
+// Allows reports whether storing n more bytes stays within the limit.
+func (q Quota) Allows(n int64) bool {
+	return q.LimitBytes <= 0 || q.UsedBytes+n <= q.LimitBytes
+}
+
+// quotaReservations holds the bytes of uploads still in flight per owner.
+// Usage is read from the database, which only counts an upload once it is
+// registered, so without them concurrent uploads from one client could each
+// pass the check against the same usage. Reservations are per instance:
+// across replicas the quota stays a soft limit.
+type quotaReservations struct {
+	mu   sync.Mutex
+	held map[string]int64
+}
+
+// reserve claims n bytes for q.Owner if q allows them on top of the bytes
+// already reserved, reporting whether it did. Unlimited quotas always pass
+// and hold nothing.
+func (r *quotaReservations) reserve(q Quota, n int64) bool {
+	if q.LimitBytes <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !q.Allows(r.held[q.Owner] + n) {
+		return false
+	}
+	if r.held == nil {
+		r.held = make(map[string]int64)
+	}
+	r.held[q.Owner] += n
+	return true
+}
+
+// release returns bytes claimed by a successful reserve.
+func (r *quotaReservations) release(q Quota, n int64) {
+	if q.LimitBytes <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.held[q.Owner] -= n
+	if r.held[q.Owner] <= 0 {
+		delete(r.held, q.Owner)
+	}
+}
+
+// quotaFor loads the current usage and configured limit for owner.
+func (h *Handler) quotaFor(ctx context.Context, owner string) (Quota, error) {
+	limit := h.cfg.Settings.Int64(settings.DefaultQuotaBytes, h.cfg.DefaultQuotaBytes)
+	if l, ok := h.cfg.ClientQuotas[owner]; ok {
+		limit = l
+	}
+
+	q := Quota{Owner: owner, LimitBytes: limit}
+	if limit <= 0 {
+		// Unlimited: skip the aggregate query on the upload hot path.
+		return q, nil
+	}
+
+	used, err := h.repo.UsageByOwner(ctx, owner)
+	if err != nil {
+		return Quota{}, err
+	}
+	q.UsedBytes = used
+	return q, nil
+}
+
+// ---------- GET /quota ----------
+
+func (h *Handler) getQuota(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	owner := clientID(r)
+	logger := h.logger.With(slog.String("request_id", requestID), slog.String("owner", owner))
+
+	logger.Info("get quota request")
+
+	q, err := h.quotaFor(r.Context(), owner)
+	if err != nil {
+		logger.Error("get quota", slog.String("error", err.Error()))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if q.LimitBytes <= 0 {
+		// Report real usage even when no limit applies.
+		if q.UsedBytes, err = h.repo.UsageByOwner(r.Context(), owner); err != nil {
+			logger.Error("get usage", slog.String("error", err.Error()))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, q)
+}
@@ -0,0 +1,203 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/mtiwari1/gopherdrive/proto"
+)
+
+// RegisterRetry configures retries and a circuit breaker around the
+// RegisterFile call an upload makes. The zero value makes one attempt and
+// never opens the breaker, which is right while the gRPC service runs in
+// process.
+type RegisterRetry struct {
+	// Attempts is the total number of tries per upload; below 2 disables
+	// retries. Only transient failures (Unavailable, DeadlineExceeded,
+	// Aborted, ResourceExhausted) are retried.
+	Attempts int
+
+	// Backoff is the delay before the first retry, doubling after each.
+	// Zero selects 100ms.
+	Backoff time.Duration
+
+	// BreakerThreshold is how many consecutive uploads must fail
+	// transiently before the breaker opens and uploads are refused with 503
+	// without calling the service. Zero disables the breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long the breaker stays open before one upload
+	// is let through to probe the service. Zero selects 30s.
+	BreakerCooldown time.Duration
+}
+
+const (
+	defaultRegisterBackoff = 100 * time.Millisecond
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// errBreakerOpen is returned without calling RegisterFile while the breaker
+// is open.
+var errBreakerOpen = errors.New("register circuit breaker open")
+
+// transientRegisterError reports whether err is worth retrying and counts
+// against the breaker. Rejections such as AlreadyExists or InvalidArgument
+// mean the service is up and are returned as is.
+func transientRegisterError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// circuitBreaker opens after BreakerThreshold consecutive transient failures.
+// Once the cooldown has passed a single probe is allowed; its outcome closes
+// the breaker or re-opens it for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// breakerStatus is the JSON form of the breaker under /admin/metrics.
+type breakerStatus struct {
+	State     string     `json:"state"` // closed, open or half_open
+	Failures  int        `json:"consecutive_failures"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// allow reports whether a call may go ahead.
+func (b *circuitBreaker) allow(cfg RegisterRetry, now time.Time) bool {
+	if cfg.BreakerThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < cfg.BreakerThreshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// rejecting reports whether allow would refuse a call now: the breaker is
+// open, or half open with its probe still in flight. Unlike allow it never
+// claims the probe, so uploads can check it before reading their body.
+func (b *circuitBreaker) rejecting(cfg RegisterRetry, now time.Time) bool {
+	if cfg.BreakerThreshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= cfg.BreakerThreshold && (now.Before(b.openUntil) || b.probing)
+}
+
+// record accounts for the outcome of an allowed call.
+func (b *circuitBreaker) record(cfg RegisterRetry, failed bool, now time.Time) {
+	if cfg.BreakerThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= cfg.BreakerThreshold {
+		cooldown := cfg.BreakerCooldown
+		if cooldown <= 0 {
+			cooldown = defaultBreakerCooldown
+		}
+		b.openUntil = now.Add(cooldown)
+	}
+}
+
+// retryAfter is how long until the breaker lets a probe through.
+func (b *circuitBreaker) retryAfter(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.openUntil.Sub(now)
+}
+
+func (b *circuitBreaker) snapshot(cfg RegisterRetry, now time.Time) breakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := breakerStatus{State: "closed", Failures: b.failures}
+	if cfg.BreakerThreshold > 0 && b.failures >= cfg.BreakerThreshold {
+		s.State = "half_open"
+		if now.Before(b.openUntil) {
+			s.State = "open"
+			openUntil := b.openUntil
+			s.OpenUntil = &openUntil
+		}
+	}
+	return s
+}
+
+// writeBreakerOpen rejects an upload while the breaker is open.
+func (h *Handler) writeBreakerOpen(w http.ResponseWriter, logger *slog.Logger) {
+	logger.Warn("upload rejected, register circuit breaker open")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(h.breaker.retryAfter(time.Now()))))
+	writeAPIError(w, http.StatusServiceUnavailable, "registry_unavailable", "file registry is unavailable, retry later")
+}
+
+// registerFile calls RegisterFile with the configured retries, behind the
+// circuit breaker. Retries stop early if ctx ends. RegisterFile is not
+// idempotent: an attempt that timed out may still have committed, so a retry
+// answered with AlreadyExists counts as success. Upload IDs are generated
+// per request, so nothing else can have taken the ID.
+func (h *Handler) registerFile(ctx context.Context, logger *slog.Logger, req *pb.RegisterFileRequest) error {
+	cfg := h.cfg.RegisterRetry
+	if !h.breaker.allow(cfg, time.Now()) {
+		return errBreakerOpen
+	}
+
+	attempts := max(cfg.Attempts, 1)
+	backoff := cfg.Backoff
+	if backoff <= 0 {
+		backoff = defaultRegisterBackoff
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		_, err = h.grpc.RegisterFile(ctx, req)
+		if attempt > 1 && status.Code(err) == codes.AlreadyExists {
+			logger.Info("grpc RegisterFile retry found the record already stored", slog.Int("attempt", attempt))
+			err = nil
+		}
+		if err == nil || !transientRegisterError(err) || attempt >= attempts {
+			break
+		}
+		logger.Warn("grpc RegisterFile failed, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			h.breaker.record(cfg, true, time.Now())
+			return err
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+	h.breaker.record(cfg, err != nil && transientRegisterError(err), time.Now())
+	return err
+}
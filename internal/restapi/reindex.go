@@ -0,0 +1,132 @@
+package restapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// reindexBatchSize is how many records one ReindexColumns call covers.
+const reindexBatchSize = 500
+
+// reindexStatus is the JSON form of the reindex job's progress.
+type reindexStatus struct {
+	State      string     `json:"state"` // idle, running, done or failed
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	LastID     string     `json:"last_id"` // pass as ?after= to resume
+	Scanned    int        `json:"scanned"`
+	Updated    int        `json:"updated"`
+	Corrupt    int        `json:"corrupt"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// reindexJob runs at most one column backfill per instance.
+type reindexJob struct {
+	mu     sync.Mutex
+	status reindexStatus
+}
+
+func (j *reindexJob) snapshot() reindexStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.status
+	if s.State == "" {
+		s.State = "idle"
+	}
+	return s
+}
+
+// ---------- POST /admin/reindex[?after=<id>] ----------
+
+// startReindex backfills the indexed columns from each record's metadata in
+// the background, batch by batch. The job is idempotent, and ?after= resumes
+// from the last_id of an interrupted run (for example after a restart).
+func (h *Handler) startReindex(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	j := &h.reindex
+	j.mu.Lock()
+	if j.status.State == "running" {
+		j.mu.Unlock()
+		writeAPIError(w, http.StatusConflict, "reindex_running", "a reindex is already running")
+		return
+	}
+	now := time.Now().UTC()
+	afterID := r.URL.Query().Get("after")
+	j.status = reindexStatus{State: "running", StartedAt: &now, LastID: afterID}
+	j.mu.Unlock()
+
+	logger.Info("reindex started", slog.String("after", afterID))
+	// The job outlives the request; it stops only when the process does.
+	go h.runReindex(context.Background(), afterID)
+
+	writeJSON(w, r, http.StatusAccepted, j.snapshot())
+}
+
+// runReindex walks the catalog until a short batch or an error.
+func (h *Handler) runReindex(ctx context.Context, afterID string) {
+	j := &h.reindex
+	finish := func(state string, err error) {
+		now := time.Now().UTC()
+		j.mu.Lock()
+		j.status.State = state
+		j.status.FinishedAt = &now
+		if err != nil {
+			j.status.Error = err.Error()
+		}
+		s := j.status
+		j.mu.Unlock()
+
+		attrs := []any{
+			slog.String("state", state),
+			slog.String("last_id", s.LastID),
+			slog.Int("scanned", s.Scanned),
+			slog.Int("updated", s.Updated),
+			slog.Int("corrupt", s.Corrupt),
+		}
+		if err != nil {
+			h.logger.Error("reindex failed", append(attrs, slog.String("error", err.Error()))...)
+			return
+		}
+		h.logger.Info("reindex finished", attrs...)
+	}
+
+	for {
+		batch, err := h.repo.ReindexColumns(ctx, afterID, reindexBatchSize)
+		if err != nil {
+			finish("failed", err)
+			return
+		}
+
+		j.mu.Lock()
+		if batch.LastID != "" {
+			j.status.LastID = batch.LastID
+		}
+		j.status.Scanned += batch.Scanned
+		j.status.Updated += batch.Updated
+		j.status.Corrupt += batch.Corrupt
+		s := j.status
+		j.mu.Unlock()
+
+		if batch.Scanned < reindexBatchSize {
+			finish("done", nil)
+			return
+		}
+		h.logger.Info("reindex progress",
+			slog.String("last_id", s.LastID),
+			slog.Int("scanned", s.Scanned),
+			slog.Int("updated", s.Updated),
+		)
+		afterID = batch.LastID
+	}
+}
+
+// ---------- GET /admin/reindex ----------
+
+func (h *Handler) getReindexStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, h.reindex.snapshot())
+}
@@ -0,0 +1,92 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/mtiwari1/gopherdrive/internal/settings"
+)
+
+// settingRequest is the body of PUT /admin/settings/{key}.
+type settingRequest struct {
+	Value *string `json:"value"`
+}
+
+// settingView is the API representation of a setting: its definition plus
+// the stored value, if any. Unset settings use their environment default.
+type settingView struct {
+	settings.Definition
+	Value string `json:"value,omitempty"`
+	Set   bool   `json:"set"`
+}
+
+func (h *Handler) viewSetting(d settings.Definition) settingView {
+	v, ok := h.cfg.Settings.Get(d.Key)
+	return settingView{Definition: d, Value: v, Set: ok}
+}
+
+// ---------- GET /admin/settings ----------
+
+func (h *Handler) listSettings(w http.ResponseWriter, r *http.Request) {
+	defs := settings.Definitions()
+	out := make([]settingView, 0, len(defs))
+	for _, d := range defs {
+		out = append(out, h.viewSetting(d))
+	}
+	writeJSON(w, r, http.StatusOK, out)
+}
+
+// ---------- GET /admin/settings/{key} ----------
+
+func (h *Handler) getSetting(w http.ResponseWriter, r *http.Request) {
+	d, ok := settings.Lookup(r.PathValue("key"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "unknown_setting", "no such setting")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, h.viewSetting(d))
+}
+
+// ---------- PUT /admin/settings/{key} ----------
+
+// putSetting stores a hot-reloadable setting. It applies to this instance
+// at once and to other instances on their next refresh.
+func (h *Handler) putSetting(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	logger := h.logger.With(slog.String("setting", key))
+
+	if h.cfg.Settings == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "settings_unavailable", "runtime settings are not configured")
+		return
+	}
+
+	var req settingRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil || req.Value == nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", "body must be a JSON object with a string value")
+		return
+	}
+
+	err := h.cfg.Settings.Set(r.Context(), key, *req.Value)
+	switch {
+	case errors.Is(err, settings.ErrUnknownKey):
+		writeAPIError(w, http.StatusNotFound, "unknown_setting", "no such setting")
+		return
+	case errors.Is(err, settings.ErrRestartOnly):
+		d, _ := settings.Lookup(key)
+		writeAPIError(w, http.StatusConflict, "restart_only", "this setting is read at startup; set "+d.Env+" and restart")
+		return
+	case errors.Is(err, settings.ErrInvalidValue):
+		writeAPIError(w, http.StatusBadRequest, "invalid_value", err.Error())
+		return
+	case err != nil:
+		logger.Error("store setting", slog.String("error", err.Error()))
+		writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		return
+	}
+
+	logger.Info("setting updated", slog.String("value", *req.Value))
+	d, _ := settings.Lookup(key)
+	writeJSON(w, r, http.StatusOK, h.viewSetting(d))
+}
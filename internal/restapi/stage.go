@@ -0,0 +1,72 @@
+package restapi
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// stageRequest asks whether content with the given hash must be uploaded.
+type stageRequest struct {
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+	Filename string `json:"filename"`
+}
+
+// ---------- POST /files/stage ----------
+
+// stageFile lets clients skip redundant transfers: 200 with the existing file
+// ID if the caller already stores identical content, otherwise 201 with where
+// to upload. Only files visible to the caller count as matches, so the
+// endpoint cannot be used to probe other clients' content by hash.
+func (h *Handler) stageFile(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	var req stageRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", "body must be a JSON object with hash, size and filename")
+		return
+	}
+	// Stored hashes are lowercase hex; accept either case from clients.
+	req.Hash = strings.ToLower(req.Hash)
+	if b, err := hex.DecodeString(req.Hash); err != nil || len(b) != 32 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_hash", "hash must be a hex-encoded SHA-256 digest")
+		return
+	}
+	if req.Size < 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_size", "size must not be negative")
+		return
+	}
+
+	logger.Info("stage request", slog.String("hash", req.Hash), slog.Int64("size", req.Size))
+
+	owner := clientID(r)
+	if h.isAdmin(r) {
+		owner = "" // admins match every owner
+	}
+
+	rec, err := h.repo.GetByHash(r.Context(), owner, req.Hash)
+	switch {
+	case err == nil && rec.Size == req.Size:
+		writeJSON(w, r, http.StatusOK, map[string]interface{}{
+			"id":     rec.ID,
+			"exists": true,
+		})
+		return
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		logger.Error("get by hash", slog.String("error", err.Error()))
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, map[string]interface{}{
+		"exists":     false,
+		"upload_url": "/files",
+		"method":     http.MethodPost,
+	})
+}
@@ -0,0 +1,79 @@
+package restapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+	pb "github.com/mtiwari1/gopherdrive/proto"
+)
+
+// statusRequest is the body of PATCH /files/{id}/status.
+type statusRequest struct {
+	Status string `json:"status"`
+}
+
+// ---------- PATCH /files/{id}/status ----------
+
+// updateStatus changes a file's status through the gRPC layer so REST and
+// gRPC clients share one state machine (see repository.CanTransition).
+func (h *Handler) updateStatus(w http.ResponseWriter, r *http.Request) {
+	requestID := requestIDFrom(r)
+	logger := h.logger.With(slog.String("request_id", requestID))
+
+	id := r.PathValue("id")
+
+	var req statusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_json", "body must be a JSON object with a status")
+		return
+	}
+	if !repository.IsValidStatus(req.Status) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_status", "status must be one of pending, completed, failed")
+		return
+	}
+
+	logger.Info("update status request", slog.String("file_id", id), slog.String("new_status", req.Status))
+
+	rec, err := h.repo.GetByID(r.Context(), id)
+	if err == nil && !h.canAccess(r, rec) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "file not found")
+		} else {
+			logger.Error("get file", slog.String("file_id", id), slog.String("error", err.Error()))
+			writeAPIError(w, http.StatusInternalServerError, "internal", "internal server error")
+		}
+		return
+	}
+	if !ifMatch(r, rec) {
+		logger.Info("status update precondition failed", slog.String("file_id", id))
+		writeAPIError(w, http.StatusPreconditionFailed, "precondition_failed", "file has changed")
+		return
+	}
+
+	resp, err := h.grpc.UpdateStatus(r.Context(), &pb.UpdateStatusRequest{Id: id, Status: req.Status})
+	if err != nil {
+		code := grpcToHTTPStatus(err)
+		logger.Warn("grpc UpdateStatus failed", slog.String("file_id", id), slog.Int("http_status", code), slog.String("error", err.Error()))
+		msg := status.Convert(err).Message()
+		if code == http.StatusInternalServerError {
+			msg = "internal server error" // don't leak database errors
+		}
+		writeAPIError(w, code, "status_update_failed", msg)
+		return
+	}
+
+	logger.Info("status updated", slog.String("file_id", id), slog.String("from", rec.Status), slog.String("to", resp.Status))
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"id":     resp.Id,
+		"status": resp.Status,
+	})
+}
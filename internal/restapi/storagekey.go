@@ -0,0 +1,118 @@
+package restapi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultStorageKey stores each upload as <id><ext> directly in the upload
+// directory.
+const DefaultStorageKey = "{id}{ext}"
+
+// errPathTraversal is returned when a resolved storage path would leave the
+// upload directory.
+var errPathTraversal = errors.New("storage path escapes the upload directory")
+
+// storageKeyFields are the placeholders a storage key template may use.
+// Dates are the upload time in UTC; {hash} is the SHA-256 of the content.
+var storageKeyFields = map[string]bool{
+	"id": true, "ext": true, "hash": true,
+	"yyyy": true, "mm": true, "dd": true, "hh": true,
+}
+
+// StorageKeyTemplate lays out stored files below the upload directory, e.g.
+// "{yyyy}/{mm}/{id}{ext}" for date partitions or "{hash}/{id}" to group
+// files by content. It is resolved once the upload has been written.
+type StorageKeyTemplate struct {
+	raw       string
+	needsHash bool
+}
+
+// ParseStorageKeyTemplate validates a template; empty selects
+// DefaultStorageKey. Each record must own its file, since deleting, expiring
+// or purging a record unlinks its path, so the template needs {id}. It must
+// also be a relative path that cannot climb out of the upload directory.
+func ParseStorageKeyTemplate(s string) (*StorageKeyTemplate, error) {
+	if s == "" {
+		s = DefaultStorageKey
+	}
+	t := &StorageKeyTemplate{raw: s}
+
+	rest := s
+	for {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			break
+		}
+		if strings.Contains(rest[:open], "}") {
+			return nil, fmt.Errorf("storage key %q: unmatched }", s)
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("storage key %q: unclosed {", s)
+		}
+		name := rest[open+1 : open+end]
+		if !storageKeyFields[name] {
+			return nil, fmt.Errorf("storage key %q: unknown placeholder {%s}", s, name)
+		}
+		if name == "hash" {
+			t.needsHash = true
+		}
+		rest = rest[open+end+1:]
+	}
+	if strings.Contains(rest, "}") {
+		return nil, fmt.Errorf("storage key %q: unmatched }", s)
+	}
+
+	if !strings.Contains(s, "{id}") {
+		return nil, fmt.Errorf("storage key %q: must contain {id}", s)
+	}
+	if filepath.IsAbs(s) {
+		return nil, fmt.Errorf("storage key %q: must be a relative path", s)
+	}
+	for _, seg := range strings.Split(filepath.ToSlash(s), "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return nil, fmt.Errorf("storage key %q: empty, . or .. path segment", s)
+		}
+	}
+	return t, nil
+}
+
+// NeedsHash reports whether resolving the template requires the content hash.
+func (t *StorageKeyTemplate) NeedsHash() bool {
+	return t.needsHash
+}
+
+// Resolve substitutes the placeholders for one upload.
+func (t *StorageKeyTemplate) Resolve(id, ext, hash string, at time.Time) string {
+	at = at.UTC()
+	return strings.NewReplacer(
+		"{id}", id,
+		"{ext}", ext,
+		"{hash}", hash,
+		"{yyyy}", at.Format("2006"),
+		"{mm}", at.Format("01"),
+		"{dd}", at.Format("02"),
+		"{hh}", at.Format("15"),
+	).Replace(t.raw)
+}
+
+// storagePath resolves the storage key for an upload to a path inside the
+// upload directory and creates its parent directories. The client-supplied
+// extension is the only untrusted input, so the result is still checked to
+// stay within the upload root.
+func (h *Handler) storagePath(id, ext, hash string, at time.Time) (string, error) {
+	root := filepath.Clean(h.uploadDir)
+	destPath := filepath.Clean(filepath.Join(root, h.storageKey.Resolve(id, ext, hash, at)))
+	if !strings.HasPrefix(destPath, root+string(os.PathSeparator)) {
+		return "", errPathTraversal
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return "", err
+	}
+	return destPath, nil
+}
@@ -0,0 +1,110 @@
+package restapi
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// newUploadTestHandler builds a Handler whose upload path fails the test if
+// it gets as far as registering or processing a file.
+func newUploadTestHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHandler(nil, nil, nil, dir, logger, Config{}), dir
+}
+
+// readTracker is a request body that records whether it was read.
+type readTracker struct {
+	read bool
+}
+
+func (r *readTracker) Read(p []byte) (int, error) {
+	r.read = true
+	return 0, io.EOF
+}
+
+func assertAPIError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, status, rec.Body)
+	}
+	var body map[string]apiError
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if got := body["error"].Code; got != code {
+		t.Errorf("error code = %q, want %q", got, code)
+	}
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("left behind in %s: %s", dir, e.Name())
+	}
+}
+
+func TestUploadRejectsDeclaredOversizeBeforeReading(t *testing.T) {
+	h, dir := newUploadTestHandler(t)
+
+	body := &readTracker{}
+	req := httptest.NewRequest(http.MethodPost, "/files", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	req.ContentLength = maxUploadBytes + 1
+	rec := httptest.NewRecorder()
+
+	h.uploadFile(rec, req)
+
+	assertAPIError(t, rec, http.StatusRequestEntityTooLarge, "request_too_large")
+	if body.read {
+		t.Error("body was read before the declared size was rejected")
+	}
+	assertEmptyDir(t, dir)
+}
+
+func TestUploadChunkedOverflow(t *testing.T) {
+	h, dir := newUploadTestHandler(t)
+	spill := t.TempDir()
+	t.Setenv("TMPDIR", spill) // where multipart spills file parts
+
+	// Stream a file part past the limit with no Content-Length.
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile(defaultUploadField, "big.bin")
+		if err == nil {
+			chunk := make([]byte, 1<<20)
+			for written := 0; written <= maxUploadBytes && err == nil; written += len(chunk) {
+				_, err = part.Write(chunk)
+			}
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close() // unblocks the writer once the handler stops reading
+
+	req := httptest.NewRequest(http.MethodPost, "/files", pr)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	rec := httptest.NewRecorder()
+
+	h.uploadFile(rec, req)
+
+	assertAPIError(t, rec, http.StatusRequestEntityTooLarge, "request_too_large")
+	assertEmptyDir(t, dir)
+	assertEmptyDir(t, spill)
+}
@@ -0,0 +1,278 @@
+// Package settings provides runtime tunables backed by the settings table.
+// Hot-reloadable settings take effect without a restart: values are cached
+// in memory, refreshed periodically and pushed to subscribers on change.
+// Restart-only settings are listed for visibility but must be changed
+// through their environment variable.
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// Kind is the value type of a setting. Values are stored as strings.
+type Kind string
+
+const (
+	KindBool     Kind = "bool"
+	KindInt      Kind = "int"
+	KindDuration Kind = "duration"
+)
+
+// Setting keys.
+const (
+	PoolPaused        = "pool_paused"
+	AnalysisTimeout   = "analysis_timeout"
+	DefaultQuotaBytes = "default_quota_bytes"
+	DownloadMaxAge    = "download_max_age"
+	MaxOpenFiles      = "max_open_files"
+	UploadTimeout     = "upload_timeout"
+)
+
+// Definition describes a known setting.
+type Definition struct {
+	Key         string `json:"key"`
+	Kind        Kind   `json:"kind"`
+	HotReload   bool   `json:"hot_reload"`
+	Env         string `json:"env,omitempty"` // environment variable giving the startup value
+	Description string `json:"description"`
+}
+
+var definitions = []Definition{
+	{PoolPaused, KindBool, true, "", "stop workers taking new jobs on every instance"},
+	{AnalysisTimeout, KindDuration, true, "ANALYSIS_TIMEOUT", "deadline for content analysis per job"},
+	{DefaultQuotaBytes, KindInt, true, "QUOTA_DEFAULT_BYTES", "storage allowance for clients without their own quota (0 = unlimited)"},
+	{DownloadMaxAge, KindDuration, true, "DOWNLOAD_MAX_AGE", "Cache-Control max-age for file downloads"},
+	{MaxOpenFiles, KindInt, false, "MAX_OPEN_FILES", "file handles shared by uploads, downloads and hashing"},
+	{UploadTimeout, KindDuration, false, "UPLOAD_TIMEOUT", "read and write deadline for POST /files"},
+}
+
+// Errors returned by Store.Set.
+var (
+	ErrUnknownKey   = errors.New("settings: unknown key")
+	ErrRestartOnly  = errors.New("settings: restart-only setting")
+	ErrInvalidValue = errors.New("settings: invalid value")
+)
+
+// Definitions returns every known setting.
+func Definitions() []Definition {
+	return append([]Definition(nil), definitions...)
+}
+
+// Lookup returns the definition for key.
+func Lookup(key string) (Definition, bool) {
+	for _, d := range definitions {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+var kindHints = map[Kind]string{
+	KindBool:     "true or false",
+	KindInt:      "a non-negative integer",
+	KindDuration: "a non-negative duration such as 30s",
+}
+
+// Validate checks that value parses as the setting's kind. Numbers and
+// durations must not be negative.
+func (d Definition) Validate(value string) error {
+	ok := false
+	switch d.Kind {
+	case KindBool:
+		_, err := strconv.ParseBool(value)
+		ok = err == nil
+	case KindInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		ok = err == nil && n >= 0
+	case KindDuration:
+		dur, err := time.ParseDuration(value)
+		ok = err == nil && dur >= 0
+	}
+	if !ok {
+		return fmt.Errorf("%w for %s: want %s, got %q", ErrInvalidValue, d.Key, kindHints[d.Kind], value)
+	}
+	return nil
+}
+
+// Store caches hot-reloadable settings. A nil *Store returns every
+// caller's default, so consumers need no nil checks.
+type Store struct {
+	repo   repository.SettingsStore
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	values map[string]string
+	hooks  map[string][]func()
+}
+
+// New creates a Store. Call Refresh to load the stored values.
+func New(repo repository.SettingsStore, logger *slog.Logger) *Store {
+	return &Store{
+		repo:   repo,
+		logger: logger,
+		values: make(map[string]string),
+		hooks:  make(map[string][]func()),
+	}
+}
+
+// OnChange registers fn to run whenever key changes, including when it is
+// first loaded. fn reads the new value through the typed getters.
+func (s *Store) OnChange(key string, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[key] = append(s.hooks[key], fn)
+}
+
+// Get returns the cached value for key and whether it is set.
+func (s *Store) Get(key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Bool returns key as a bool, or def if unset or invalid.
+func (s *Store) Bool(key string, def bool) bool {
+	if v, ok := s.Get(key); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// Int64 returns key as an int64, or def if unset or invalid.
+func (s *Store) Int64(key string, def int64) int64 {
+	if v, ok := s.Get(key); ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// Duration returns key as a duration, or def if unset or invalid.
+func (s *Store) Duration(key string, def time.Duration) time.Duration {
+	if v, ok := s.Get(key); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// Set validates and stores a hot-reloadable setting, then applies it to
+// this instance immediately. Other instances pick it up on their next
+// Refresh.
+func (s *Store) Set(ctx context.Context, key, value string) error {
+	d, ok := Lookup(key)
+	if !ok {
+		return ErrUnknownKey
+	}
+	if !d.HotReload {
+		return ErrRestartOnly
+	}
+	if err := d.Validate(value); err != nil {
+		return err
+	}
+	if err := s.repo.SetSetting(ctx, key, value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	changed := s.values[key] != value
+	s.values[key] = value
+	s.mu.Unlock()
+	if changed {
+		s.notify([]string{key})
+	}
+	return nil
+}
+
+// Refresh reloads hot-reloadable settings from the store and notifies
+// subscribers of changed keys. Unknown, restart-only and invalid rows are
+// ignored.
+func (s *Store) Refresh(ctx context.Context) error {
+	stored, err := s.repo.ListSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	next := make(map[string]string, len(stored))
+	for key, value := range stored {
+		d, ok := Lookup(key)
+		if !ok || !d.HotReload {
+			continue
+		}
+		if err := d.Validate(value); err != nil {
+			s.logger.Warn("ignoring invalid setting", slog.String("error", err.Error()))
+			continue
+		}
+		next[key] = value
+	}
+
+	s.mu.Lock()
+	var changed []string
+	for _, d := range definitions {
+		old, hadOld := s.values[d.Key]
+		v, has := next[d.Key]
+		if hadOld != has || old != v {
+			changed = append(changed, d.Key)
+		}
+	}
+	s.values = next
+	s.mu.Unlock()
+
+	s.notify(changed)
+	return nil
+}
+
+// notify runs the hooks for keys outside the lock.
+func (s *Store) notify(keys []string) {
+	for _, key := range keys {
+		s.mu.RLock()
+		hooks := append([]func(){}, s.hooks[key]...)
+		s.mu.RUnlock()
+
+		s.logger.Info("setting changed", slog.String("key", key))
+		for _, fn := range hooks {
+			fn()
+		}
+	}
+}
+
+// defaultRefresh is used by Run when interval is not positive.
+const defaultRefresh = 30 * time.Second
+
+// Run refreshes the cache every interval until ctx is cancelled. A zero or
+// negative interval selects 30s.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRefresh
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Refresh(ctx); err != nil {
+			s.logger.Error("refresh settings", slog.String("error", err.Error()))
+		}
+	}
+}
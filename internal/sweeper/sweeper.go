@@ -0,0 +1,97 @@
+// Package sweeper runs periodic cleanup tasks for artifacts that outlive the
+// requests that created them (abandoned temp files and the like).
+package sweeper
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// TaskFunc removes stale artifacts and reports how many it cleaned.
+type TaskFunc func(ctx context.Context) (int, error)
+
+type task struct {
+	name string
+	fn   TaskFunc
+}
+
+// Sweeper runs its registered tasks one after another on a fixed interval.
+type Sweeper struct {
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	tasks []task
+	gate  func() bool
+}
+
+// New creates a Sweeper. Register tasks, then call Run.
+func New(interval time.Duration, logger *slog.Logger) *Sweeper {
+	return &Sweeper{interval: interval, logger: logger}
+}
+
+// Register adds a named task. It is safe to call while Run is active; the
+// task joins the next sweep.
+func (s *Sweeper) Register(name string, fn TaskFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, task{name: name, fn: fn})
+}
+
+// OnlyWhen makes sweeps run only while cond reports true, for example only
+// on the elected leader among replicas.
+func (s *Sweeper) OnlyWhen(cond func() bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gate = cond
+}
+
+// Run sweeps every interval until ctx is cancelled. A task in progress sees
+// the cancellation through its ctx; Run returns once it has stopped.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.sweep(ctx)
+	}
+}
+
+// sweep runs each task once, logging its outcome.
+func (s *Sweeper) sweep(ctx context.Context) {
+	s.mu.Lock()
+	tasks := append([]task(nil), s.tasks...)
+	gate := s.gate
+	s.mu.Unlock()
+	if gate != nil && !gate() {
+		return
+	}
+
+	for _, t := range tasks {
+		if ctx.Err() != nil {
+			return
+		}
+		start := time.Now()
+		n, err := t.fn(ctx)
+		logger := s.logger.With(
+			slog.String("task", t.name),
+			slog.Int("cleaned", n),
+			slog.Duration("duration", time.Since(start)),
+		)
+		switch {
+		case err != nil:
+			logger.Error("sweep task failed", slog.String("error", err.Error()))
+		case n > 0:
+			logger.Info("sweep task cleaned artifacts")
+		default:
+			logger.Debug("sweep task found nothing to clean")
+		}
+	}
+}
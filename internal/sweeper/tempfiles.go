@@ -0,0 +1,41 @@
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TempFiles returns a task that deletes files in dir matching pattern (a
+// filepath.Match glob) last modified more than maxAge ago. maxAge must
+// comfortably exceed the longest upload, since in-progress files match too.
+func TempFiles(dir, pattern string, maxAge time.Duration) TaskFunc {
+	return func(ctx context.Context) (int, error) {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return 0, fmt.Errorf("sweeper: glob %s: %w", pattern, err)
+		}
+
+		cutoff := time.Now().Add(-maxAge)
+		removed := 0
+		var errs []error
+		for _, path := range matches {
+			if err := ctx.Err(); err != nil {
+				return removed, err
+			}
+			fi, err := os.Lstat(path)
+			if err != nil || !fi.Mode().IsRegular() || fi.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+				continue
+			}
+			removed++
+		}
+		return removed, errors.Join(errs...)
+	}
+}
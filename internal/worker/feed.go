@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mtiwari1/gopherdrive/internal/repository"
+)
+
+// Feed claims jobs from a durable queue and submits them to the pool until
+// ctx is cancelled or the pool shuts down. It polls every interval while the
+// queue is empty and claims back-to-back otherwise; Submit's backpressure
+// keeps an instance from claiming far more than it can process. Results carry
+// the QueueID so the consumer can Complete the job once it is persisted. Each
+// job renews its claim lease when a worker starts it, so time spent queued in
+// the pool does not let another instance re-claim it mid-processing.
+func Feed(ctx context.Context, q repository.JobQueue, pool *Pool, interval time.Duration, logger *slog.Logger) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		for ctx.Err() == nil {
+			qj, err := q.Claim(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			if err != nil {
+				logger.Error("queue claim", slog.String("error", err.Error()))
+				break
+			}
+
+			if !pool.Submit(Job{
+				Ctx:        context.Background(),
+				QueueID:    qj.ID,
+				Epoch:      qj.Epoch,
+				FileID:     qj.FileID,
+				FilePath:   qj.FilePath,
+				Extractors: qj.Extractors,
+				Renew: func(ctx context.Context) error {
+					return q.Renew(ctx, qj.ID, qj.Token)
+				},
+			}) {
+				// The claim lapses after its lease and another instance
+				// picks the job up.
+				logger.Warn("pool closed, releasing claimed job to lease expiry", slog.Int64("queue_id", qj.ID))
+				return
+			}
+		}
+		timer.Reset(interval)
+	}
+}
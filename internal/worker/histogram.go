@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the processing-latency histogram bounds used when
+// Config.LatencyBuckets is empty. They are dense below 100ms, where text and
+// small image jobs land, and stretch to 60s for large media.
+var DefaultLatencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+// HistogramBucket counts jobs that took at most UpperBound. Counts are
+// cumulative, as in Prometheus; the implicit +Inf bucket is Histogram.Count.
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"le_ns"`
+	Count      int64         `json:"count"`
+}
+
+// LatencyHistogram is a snapshot of the processing-latency histogram.
+type LatencyHistogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Count   int64             `json:"count"`
+	Sum     time.Duration     `json:"sum_ns"`
+}
+
+// ParseLatencyBuckets parses a comma-separated list of durations such as
+// "5ms,50ms,1s". Bounds must be positive and strictly increasing.
+func ParseLatencyBuckets(s string) ([]time.Duration, error) {
+	var bounds []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, fmt.Errorf("latency bucket %q: %w", part, err)
+		}
+		if d <= 0 || (len(bounds) > 0 && d <= bounds[len(bounds)-1]) {
+			return nil, fmt.Errorf("latency buckets must be positive and strictly increasing, got %q", s)
+		}
+		bounds = append(bounds, d)
+	}
+	return bounds, nil
+}
+
+// latencyHistogram is a fixed-bucket histogram updated lock-free on the job
+// hot path. counts has one slot per bound plus a final overflow slot.
+type latencyHistogram struct {
+	bounds []time.Duration
+	counts []atomic.Int64
+	sum    atomic.Int64 // nanoseconds
+}
+
+// newLatencyHistogram builds a histogram over bounds, falling back to
+// DefaultLatencyBuckets when bounds is empty. Bounds are sorted and
+// de-duplicated defensively.
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	return &latencyHistogram{
+		bounds: bounds,
+		counts: make([]atomic.Int64, len(bounds)+1),
+	}
+}
+
+// observe records one job latency.
+func (h *latencyHistogram) observe(latency time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return latency <= h.bounds[i] })
+	h.counts[i].Add(1)
+	h.sum.Add(int64(latency))
+}
+
+// snapshot returns cumulative bucket counts.
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	out := LatencyHistogram{
+		Buckets: make([]HistogramBucket, len(h.bounds)),
+		Sum:     time.Duration(h.sum.Load()),
+	}
+	var cum int64
+	for i, b := range h.bounds {
+		cum += h.counts[i].Load()
+		out.Buckets[i] = HistogramBucket{UpperBound: b, Count: cum}
+	}
+	out.Count = cum + h.counts[len(h.bounds)].Load()
+	return out
+}
+
+// reset zeroes all buckets.
+func (h *latencyHistogram) reset() {
+	for i := range h.counts {
+		h.counts[i].Store(0)
+	}
+	h.sum.Store(0)
+}
+
+// LatencyHistogram returns the processing-latency histogram. Jobs cancelled
+// before they started are not observed.
+func (p *Pool) LatencyHistogram() LatencyHistogram {
+	return p.latency.snapshot()
+}
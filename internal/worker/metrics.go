@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ewmaAlpha weights the newest latency sample in the moving average.
+	ewmaAlpha = 0.2
+
+	// throughputWindow is the span covered by PoolMetrics.JobsPerSecond.
+	throughputWindow = 60 // seconds, one bucket per second
+
+	// defaultMetricsFlush is used when Config.MetricsFlushInterval is not positive.
+	defaultMetricsFlush = 5 * time.Second
+)
+
+// PoolMetrics is a published snapshot of pool-wide rolling metrics.
+type PoolMetrics struct {
+	EWMALatency   time.Duration `json:"ewma_latency_ns"`
+	JobsLastMin   int64         `json:"jobs_last_minute"`
+	JobsPerSecond float64       `json:"jobs_per_second"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// rollingMetrics accumulates samples on the job hot path; flush turns them
+// into a PoolMetrics snapshot that readers load without locking.
+type rollingMetrics struct {
+	mu      sync.Mutex
+	ewma    float64                 // nanoseconds
+	buckets [throughputWindow]int64 // completions per second, ring-indexed by unix second
+	stamps  [throughputWindow]int64 // unix second each bucket currently counts
+
+	snapshot atomic.Pointer[PoolMetrics]
+}
+
+// observe records one completed job.
+func (m *rollingMetrics) observe(latency time.Duration, now time.Time) {
+	sec := now.Unix()
+	i := sec % throughputWindow
+
+	m.mu.Lock()
+	if m.ewma == 0 {
+		m.ewma = float64(latency)
+	} else {
+		m.ewma = ewmaAlpha*float64(latency) + (1-ewmaAlpha)*m.ewma
+	}
+	if m.stamps[i] != sec {
+		m.stamps[i] = sec
+		m.buckets[i] = 0
+	}
+	m.buckets[i]++
+	m.mu.Unlock()
+}
+
+// flush publishes a fresh snapshot covering the last throughputWindow seconds.
+func (m *rollingMetrics) flush(now time.Time) {
+	cutoff := now.Unix() - throughputWindow
+
+	m.mu.Lock()
+	ewma := m.ewma
+	var jobs int64
+	for i := range m.buckets {
+		if m.stamps[i] > cutoff {
+			jobs += m.buckets[i]
+		}
+	}
+	m.mu.Unlock()
+
+	m.snapshot.Store(&PoolMetrics{
+		EWMALatency:   time.Duration(ewma),
+		JobsLastMin:   jobs,
+		JobsPerSecond: float64(jobs) / throughputWindow,
+		UpdatedAt:     now,
+	})
+}
+
+// reset clears all samples and publishes an empty snapshot.
+func (m *rollingMetrics) reset(now time.Time) {
+	m.mu.Lock()
+	m.ewma = 0
+	m.buckets = [throughputWindow]int64{}
+	m.stamps = [throughputWindow]int64{}
+	m.mu.Unlock()
+	m.flush(now)
+}
+
+// Metrics returns the most recently published rolling metrics snapshot.
+// It is refreshed every Config.MetricsFlushInterval, not on read.
+func (p *Pool) Metrics() PoolMetrics {
+	if s := p.metrics.snapshot.Load(); s != nil {
+		return *s
+	}
+	return PoolMetrics{}
+}
+
+// flushMetrics publishes snapshots every interval until stop is closed.
+func (p *Pool) flushMetrics(interval time.Duration, stop <-chan struct{}) {
+	defer p.metricsWG.Done()
+	if interval <= 0 {
+		interval = defaultMetricsFlush
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			p.metrics.flush(time.Now())
+			return
+		case now := <-ticker.C:
+			p.metrics.flush(now)
+		}
+	}
+}
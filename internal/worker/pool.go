@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/mtiwari1/gopherdrive/internal/eventlog"
+	"github.com/mtiwari1/gopherdrive/internal/fdlimit"
 	"github.com/mtiwari1/gopherdrive/internal/hasher"
 )
 
@@ -17,122 +20,464 @@ type Job struct {
 	Ctx      context.Context
 	FileID   string
 	FilePath string
+
+	// QueueID identifies the job in a durable queue (see Feed); zero for
+	// jobs submitted directly.
+	QueueID int64
+
+	// Epoch is the record's processing epoch when the job was created; the
+	// consumer applies the result only if the record is still at it.
+	Epoch int64
+
+	// Extractors toggles content extractors for this upload (see hasher.Options).
+	Extractors map[string]bool
+
+	// Renew, if set, is called when the job starts processing to extend its
+	// durable queue claim, which may have aged while the job waited in a
+	// lane or a paused pool. An error abandons the job unprocessed.
+	Renew func(ctx context.Context) error
 }
 
 // Result holds the outcome of processing a single job.
 type Result struct {
+	WorkerID  int   // ID of the worker that processed the job
+	QueueID   int64 // Job.QueueID, zero unless fed from a durable queue
+	Epoch     int64 // Job.Epoch
 	FileID    string
+	FilePath  string
 	Hash      string
 	Size      int64
 	Extension string
 	Metadata  map[string]interface{}
 	Err       error
+
+	// Abandoned is set when the job was cut short by Cancel or its queue
+	// claim could not be renewed. The file was not processed and should be left for recovery to pick up again, not
+	// marked failed.
+	Abandoned bool
+}
+
+// Config holds tunables for the worker pool. The zero value applies no limits.
+type Config struct {
+	// AnalysisTimeout bounds content-specific metadata extraction per job.
+	AnalysisTimeout time.Duration
+
+	// TreeHashThreshold and TreeHashChunkSize enable parallel tree hashing
+	// for large files (see hasher.Options).
+	TreeHashThreshold int64
+	TreeHashChunkSize int64
+
+	// MimeFromExtension falls back to the extension's MIME type when
+	// sniffing is inconclusive (see hasher.Options).
+	MimeFromExtension bool
+
+	// MetricsFlushInterval is how often rolling metrics are republished.
+	// Zero selects a 5s default.
+	MetricsFlushInterval time.Duration
+
+	// Events receives per-file processing events. Nil discards them.
+	Events *eventlog.Log
+
+	// LatencyBuckets are the upper bounds of the processing-latency
+	// histogram. Empty selects DefaultLatencyBuckets.
+	LatencyBuckets []time.Duration
+
+	// FileLimiter bounds open file handles across the server; each job holds
+	// one slot while hashing. Nil means unlimited.
+	FileLimiter *fdlimit.Limiter
+
+	// LogSampleRate logs the "processing started/completed" lines for one
+	// job in N. Failures and cancellations are always logged. Values below
+	// 2 log every job.
+	LogSampleRate int
+
+	// SmallFileThreshold and LargeFileThreshold route jobs into priority
+	// lanes by file size: files of at most SmallFileThreshold bytes jump the
+	// queue and files of at least LargeFileThreshold bytes wait behind the
+	// rest. Zero disables either lane.
+	SmallFileThreshold int64
+	LargeFileThreshold int64
+
+	// ShortHashLength records a display prefix of each hash (see
+	// hasher.Options) and logs it in place of the full hash. Zero disables it.
+	ShortHashLength int
 }
 
 // Pool manages a fixed set of worker goroutines that process Jobs from a channel
 // and emit Results to another channel.
 type Pool struct {
 	workers int
-	jobs    chan Job
+	lanes   [numPriorities]chan Job // indexed by Priority
 	results chan Result
 	wg      sync.WaitGroup
 	ctx     context.Context
 	cancel  context.CancelFunc
 	logger  *slog.Logger
+	cfg     Config
+	stats   []workerCounters // indexed by worker ID
+
+	metrics     rollingMetrics
+	latency     *latencyHistogram
+	metricsStop chan struct{}
+	metricsWG   sync.WaitGroup
+
+	// submitMu is the shutdown barrier: Submit holds it for reading while it
+	// enqueues, Shutdown takes it for writing before closing the lanes.
+	// stopping is closed first, so a Submit blocked on a full lane gives up
+	// its read lock instead of holding Shutdown off.
+	submitMu     sync.RWMutex
+	closed       bool
+	stopping     chan struct{}
+	stoppingOnce sync.Once
+
+	// analysisTimeout overrides cfg.AnalysisTimeout at runtime, in nanoseconds.
+	analysisTimeout atomic.Int64
+
+	// logSeq numbers jobs for LogSampleRate.
+	logSeq atomic.Uint64
+
+	// resume is nil while running; while paused it is an open channel that
+	// Resume closes to release the workers. pausing is open while running
+	// and closed by Pause, waking workers blocked waiting for a job.
+	pauseMu sync.Mutex
+	resume  chan struct{}
+	pausing chan struct{}
 }
 
 // NewPool creates a pool with the given number of workers.
 // Call Start() to launch the goroutines.
-func NewPool(workers int, logger *slog.Logger) *Pool {
+func NewPool(workers int, logger *slog.Logger, cfg Config) *Pool {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Pool{
+	p := &Pool{
 		workers: workers,
-		jobs:    make(chan Job, workers*2),   // small buffer for backpressure
 		results: make(chan Result, workers*2),
 		ctx:     ctx,
 		cancel:  cancel,
 		logger:  logger,
+		cfg:     cfg,
+		stats:   make([]workerCounters, workers),
+
+		latency:     newLatencyHistogram(cfg.LatencyBuckets),
+		metricsStop: make(chan struct{}),
+		stopping:    make(chan struct{}),
+		pausing:     make(chan struct{}),
 	}
+	for i := range p.lanes {
+		p.lanes[i] = make(chan Job, workers*2) // small buffer for backpressure
+	}
+	p.analysisTimeout.Store(int64(cfg.AnalysisTimeout))
+	return p
 }
 
-// Start launches worker goroutines. Each reads from the jobs channel until it is
-// closed or the context is cancelled.
+// Start launches worker goroutines. Each reads from the priority lanes until
+// they are closed or the context is cancelled.
 func (p *Pool) Start() {
 	for i := 0; i < p.workers; i++ {
 		p.wg.Add(1)
 		go p.worker(i)
 	}
+
+	p.metrics.flush(time.Now())
+	p.metricsWG.Add(1)
+	go p.flushMetrics(p.cfg.MetricsFlushInterval, p.metricsStop)
 }
 
-// Submit enqueues a job. It blocks if the jobs channel buffer is full (backpressure).
-// Returns false if the pool context is already cancelled.
+// Submit enqueues a job in the lane for its file size (see Config). It blocks
+// if that lane's buffer is full (backpressure).
+// Returns false if the pool is shutting down or its context is cancelled; it
+// never sends on a closed channel.
 func (p *Pool) Submit(job Job) bool {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	if p.closed || p.ctx.Err() != nil {
+		return false
+	}
 	select {
-	case p.jobs <- job:
+	case p.lanes[p.priorityFor(job)] <- job:
 		return true
+	case <-p.stopping:
+		return false
 	case <-p.ctx.Done():
 		return false
 	}
 }
 
+// TrySubmit enqueues job only if its lane has room. It never blocks and
+// returns false when the queue is full or the pool is shut down.
+func (p *Pool) TrySubmit(job Job) bool {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	if p.closed || p.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case p.lanes[p.priorityFor(job)] <- job:
+		return true
+	default:
+		return false
+	}
+}
+
 // Results returns the read-only results channel for the consumer.
 func (p *Pool) Results() <-chan Result {
 	return p.results
 }
 
-// Shutdown closes the jobs channel, waits for all workers to finish,
-// then closes the results channel. Safe to call once.
+// Shutdown closes the job lanes, waits for all workers to finish,
+// then closes the results channel.
+//
+// Ordering guarantee: every Submit that returned true happened before the
+// lanes were closed, so its job is processed and its Result delivered before
+// Results is closed. Submits that start after Shutdown, or are still blocked
+// on a full lane when it starts, return false. A paused pool is resumed first
+// and cannot be paused again, so the queue always drains. It is safe to call
+// more than once.
 func (p *Pool) Shutdown() {
-	close(p.jobs) // signal workers to drain and exit
-	p.wg.Wait()   // wait for all workers to complete
+	p.stoppingOnce.Do(func() { close(p.stopping) })
+	p.Resume() // a paused pool could never drain
+
+	p.submitMu.Lock()
+	if p.closed {
+		p.submitMu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, lane := range p.lanes {
+		close(lane) // signal workers to drain and exit
+	}
+	p.submitMu.Unlock()
+
+	p.wg.Wait() // wait for all workers to complete
 	close(p.results)
+	p.cancel() // release the pool context
+
+	close(p.metricsStop)
+	p.metricsWG.Wait()
+}
+
+// Cancel aborts the pool: in-flight jobs see their context cancelled, queued
+// jobs are abandoned and results that cannot be delivered immediately are
+// dropped. Workers exit promptly even if nobody reads Results. Shutdown must
+// still be called to close the channels; after Cancel it no longer waits for
+// queued work.
+func (p *Pool) Cancel() {
+	p.cancel()
+}
+
+// emit delivers res to the consumer unless the pool has been cancelled.
+// Buffered space is always used first so a late cancel does not discard
+// results that could have been delivered.
+func (p *Pool) emit(res Result) {
+	select {
+	case p.results <- res:
+		return
+	default:
+	}
+
+	select {
+	case p.results <- res:
+	case <-p.ctx.Done():
+		p.logger.Warn("result dropped: pool cancelled",
+			slog.Int("worker_id", res.WorkerID),
+			slog.String("file_id", res.FileID),
+		)
+	}
+}
+
+// Pause stops workers from taking new jobs. Jobs already running finish, and
+// Submit keeps queueing (blocking once the buffer is full) until Resume.
+// Pausing a paused or shutting-down pool is a no-op.
+func (p *Pool) Pause() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	select {
+	case <-p.stopping:
+		return
+	default:
+	}
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		close(p.pausing)
+	}
+}
+
+// Resume lets workers take jobs again. Resuming a running pool is a no-op.
+func (p *Pool) Resume() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resume != nil {
+		close(p.resume)
+		p.resume = nil
+		p.pausing = make(chan struct{})
+	}
+}
+
+// pauseSignal returns a channel that is closed once the pool is paused.
+func (p *Pool) pauseSignal() <-chan struct{} {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	return p.pausing
+}
+
+// Paused reports whether the pool is paused.
+func (p *Pool) Paused() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	return p.resume != nil
+}
+
+// SetAnalysisTimeout changes the content-analysis deadline for jobs that
+// start after the call. Zero means no timeout.
+func (p *Pool) SetAnalysisTimeout(d time.Duration) {
+	p.analysisTimeout.Store(int64(d))
+}
+
+// logSampleRate is cfg.LogSampleRate clamped to at least 1.
+func (p *Pool) logSampleRate() int {
+	return max(p.cfg.LogSampleRate, 1)
 }
 
-// worker is the goroutine body. It processes jobs until the channel is closed
+// sampleLog reports whether the next job's routine log lines are emitted.
+func (p *Pool) sampleLog() bool {
+	n := uint64(p.logSampleRate())
+	return n == 1 || p.logSeq.Add(1)%n == 1
+}
+
+// hashAttr logs hash in full, or as short_hash when ShortHashLength is set.
+func (p *Pool) hashAttr(hash string) slog.Attr {
+	if p.cfg.ShortHashLength > 0 {
+		return slog.String("short_hash", hasher.ShortHash(hash, p.cfg.ShortHashLength))
+	}
+	return slog.String("hash", hash)
+}
+
+// QueueDepth returns the number of submitted jobs not yet taken by a worker.
+func (p *Pool) QueueDepth() int {
+	n := 0
+	for _, lane := range p.lanes {
+		n += len(lane)
+	}
+	return n
+}
+
+// waitResumed blocks while the pool is paused. It returns false if the pool
+// context is cancelled first.
+func (p *Pool) waitResumed() bool {
+	p.pauseMu.Lock()
+	resume := p.resume
+	p.pauseMu.Unlock()
+	if resume == nil {
+		return true
+	}
+
+	select {
+	case <-resume:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// recordJob updates the per-worker counters, the pool-wide rolling metrics
+// and the latency histogram.
+func (p *Pool) recordJob(workerID int, latency time.Duration, failed bool) {
+	p.stats[workerID].record(latency, failed)
+	p.metrics.observe(latency, time.Now())
+	p.latency.observe(latency)
+}
+
+// worker is the goroutine body. It processes jobs until the lanes are closed
 // or the context is cancelled, preventing goroutine leaks.
 func (p *Pool) worker(id int) {
 	defer p.wg.Done()
 
+	lanes := p.lanes
 	for {
-		select {
-		case job, ok := <-p.jobs:
-			if !ok {
-				// Channel closed — exit cleanly.
-				p.logger.Info("worker exiting", slog.Int("worker_id", id))
+		job, ok := p.nextJob(&lanes)
+		if !ok {
+			if p.ctx.Err() != nil {
+				p.logger.Info("worker cancelled", slog.Int("worker_id", id))
 				return
 			}
-			p.process(id, job)
-
-		case <-p.ctx.Done():
+			// Lanes closed and drained — exit cleanly.
+			p.logger.Info("worker exiting", slog.Int("worker_id", id))
+			return
+		}
+		// A Pause that lands between nextJob's check and the dequeue must
+		// still hold the job back until Resume.
+		if !p.waitResumed() {
 			p.logger.Info("worker cancelled", slog.Int("worker_id", id))
 			return
 		}
+		p.process(id, job)
 	}
 }
 
 // process handles a single job: logs start/end, computes metadata, sends result.
 // Respects the job's context for cancellation.
 func (p *Pool) process(workerID int, job Job) {
+	// Decided once per job so its started and completed lines stay paired.
+	logged := p.sampleLog()
+
 	// Use the job's context; fall back to background if nil.
 	ctx := job.Ctx
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	// Cancelling the pool also cancels the job in flight.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
 
 	// Check if context is already cancelled before doing work.
 	if err := ctx.Err(); err != nil {
-		p.results <- Result{FileID: job.FileID, Err: fmt.Errorf("job cancelled before processing: %w", err)}
+		p.stats[workerID].record(0, true) // never ran: keep it out of the latency EWMA
+		p.cfg.Events.Append(job.FileID, "cancelled", "before processing: "+err.Error())
+		p.emit(Result{WorkerID: workerID, QueueID: job.QueueID, Epoch: job.Epoch, FileID: job.FileID, Err: fmt.Errorf("job cancelled before processing: %w", err), Abandoned: p.ctx.Err() != nil})
 		return
 	}
 
+	if job.Renew != nil {
+		if err := job.Renew(ctx); err != nil {
+			// Another instance may have re-claimed the job; processing it
+			// here as well would do the work twice.
+			p.stats[workerID].record(0, true)
+			p.cfg.Events.Append(job.FileID, "cancelled", "claim renewal: "+err.Error())
+			p.emit(Result{WorkerID: workerID, QueueID: job.QueueID, Epoch: job.Epoch, FileID: job.FileID, Err: fmt.Errorf("renew job claim: %w", err), Abandoned: true})
+			return
+		}
+	}
+
 	start := time.Now()
-	p.logger.Info("processing started",
-		slog.Int("worker_id", workerID),
-		slog.String("file_id", job.FileID),
-		slog.Time("start_time", start),
-	)
+	if logged {
+		p.logger.Info("processing started",
+			slog.Int("worker_id", workerID),
+			slog.String("file_id", job.FileID),
+			slog.Time("start_time", start),
+			slog.Int("sample_rate", p.logSampleRate()),
+		)
+	}
+	p.cfg.Events.Append(job.FileID, "processing_started", fmt.Sprintf("worker %d", workerID))
 
-	meta, err := hasher.ComputeMetadata(job.FilePath)
+	analysisTimeout := time.Duration(p.analysisTimeout.Load())
+
+	// A failed acquire means ctx is done; the cancellation check below reports it.
+	var meta *hasher.Metadata
+	err := p.cfg.FileLimiter.Acquire(ctx, 1)
+	if err == nil {
+		meta, err = hasher.ComputeMetadata(ctx, job.FilePath, hasher.Options{
+			AnalysisTimeout:   analysisTimeout,
+			TreeHashThreshold: p.cfg.TreeHashThreshold,
+			TreeHashChunkSize: p.cfg.TreeHashChunkSize,
+			Extractors:        job.Extractors,
+			MimeFromExtension: p.cfg.MimeFromExtension,
+			ShortHashLength:   p.cfg.ShortHashLength,
+		})
+		p.cfg.FileLimiter.Release(1)
+	}
 
 	end := time.Now()
 	latency := end.Sub(start)
@@ -143,7 +488,9 @@ func (p *Pool) process(workerID int, job Job) {
 			slog.Int("worker_id", workerID),
 			slog.String("file_id", job.FileID),
 		)
-		p.results <- Result{FileID: job.FileID, Err: fmt.Errorf("job cancelled during processing: %w", ctx.Err())}
+		p.recordJob(workerID, latency, true)
+		p.cfg.Events.Append(job.FileID, "cancelled", "during processing after "+latency.String())
+		p.emit(Result{WorkerID: workerID, QueueID: job.QueueID, Epoch: job.Epoch, FileID: job.FileID, Err: fmt.Errorf("job cancelled during processing: %w", ctx.Err()), Abandoned: p.ctx.Err() != nil})
 		return
 	}
 
@@ -154,25 +501,43 @@ func (p *Pool) process(workerID int, job Job) {
 			slog.Duration("latency", latency),
 			slog.String("error", err.Error()),
 		)
-		p.results <- Result{FileID: job.FileID, Err: err}
+		p.recordJob(workerID, latency, true)
+		p.cfg.Events.Append(job.FileID, "processing_failed", err.Error())
+		p.emit(Result{WorkerID: workerID, QueueID: job.QueueID, Epoch: job.Epoch, FileID: job.FileID, Err: err})
 		return
 	}
 
-	p.logger.Info("processing completed",
-		slog.Int("worker_id", workerID),
-		slog.String("file_id", job.FileID),
-		slog.Time("end_time", end),
-		slog.Duration("latency", latency),
-		slog.String("hash", meta.Hash),
-		slog.Int64("size", meta.Size),
-		slog.String("extension", meta.Extension),
-	)
+	if logged {
+		p.logger.Info("processing completed",
+			slog.Int("worker_id", workerID),
+			slog.String("file_id", job.FileID),
+			slog.Time("end_time", end),
+			slog.Duration("latency", latency),
+			p.hashAttr(meta.Hash),
+			slog.Int64("size", meta.Size),
+			slog.String("extension", meta.Extension),
+			slog.Int("sample_rate", p.logSampleRate()),
+		)
+	}
 
-	p.results <- Result{
+	p.recordJob(workerID, latency, false)
+	p.cfg.Events.Append(job.FileID, "hashed", fmt.Sprintf("%d bytes, hash %s", meta.Size, hasher.ShortHash(meta.Hash, p.cfg.ShortHashLength)))
+	if mt, _ := meta.Extra["mime_type"].(string); mt != "" {
+		p.cfg.Events.Append(job.FileID, "analyzed", mt)
+	}
+	if timedOut, _ := meta.Extra["analysis_timeout"].(bool); timedOut {
+		p.cfg.Events.Append(job.FileID, "analysis_timeout", "content analysis exceeded "+analysisTimeout.String())
+	}
+	p.cfg.Events.Append(job.FileID, "processing_completed", latency.String())
+	p.emit(Result{
+		WorkerID:  workerID,
+		QueueID:   job.QueueID,
+		Epoch:     job.Epoch,
 		FileID:    job.FileID,
+		FilePath:  job.FilePath,
 		Hash:      meta.Hash,
 		Size:      meta.Size,
 		Extension: meta.Extension,
 		Metadata:  meta.Extra,
-	}
+	})
 }
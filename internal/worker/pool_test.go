@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mtiwari1/gopherdrive/internal/fdlimit"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// testJobs writes n small files and returns a job for each.
+func testJobs(t *testing.T, n int) []Job {
+	t.Helper()
+	dir := t.TempDir()
+	jobs := make([]Job, n)
+	for i := range jobs {
+		path := filepath.Join(dir, "file-"+strconv.Itoa(i)+".txt")
+		if err := os.WriteFile(path, []byte("hello world\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		jobs[i] = Job{Ctx: context.Background(), FileID: "id-" + strconv.Itoa(i), FilePath: path}
+	}
+	return jobs
+}
+
+// collect drains results until Shutdown closes the channel.
+func collect(p *Pool) <-chan []Result {
+	out := make(chan []Result, 1)
+	go func() {
+		var got []Result
+		for res := range p.Results() {
+			got = append(got, res)
+		}
+		out <- got
+	}()
+	return out
+}
+
+func TestPoolShutdownNoLeak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	p := NewPool(3, testLogger(), Config{})
+	p.Start()
+	results := collect(p)
+
+	jobs := testJobs(t, 10)
+	for _, job := range jobs {
+		if !p.Submit(job) {
+			t.Fatalf("Submit(%s) = false", job.FileID)
+		}
+	}
+	p.Shutdown()
+
+	got := <-results
+	if len(got) != len(jobs) {
+		t.Fatalf("got %d results, want %d", len(got), len(jobs))
+	}
+	for _, res := range got {
+		if res.Err != nil {
+			t.Errorf("%s: %v", res.FileID, res.Err)
+		}
+	}
+	if p.Submit(jobs[0]) {
+		t.Error("Submit after Shutdown = true")
+	}
+}
+
+func TestPoolCancelShutdownNoLeak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	p := NewPool(2, testLogger(), Config{})
+	p.Start()
+	for _, job := range testJobs(t, 4) {
+		p.Submit(job)
+	}
+
+	// Nobody reads Results: Cancel must still let every worker exit.
+	p.Cancel()
+	p.Shutdown()
+}
+
+func TestPoolPauseShutdownNoLeak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	p := NewPool(2, testLogger(), Config{})
+	p.Start()
+	results := collect(p)
+
+	p.Pause()
+	jobs := testJobs(t, 3)
+	for _, job := range jobs {
+		if !p.Submit(job) {
+			t.Fatalf("Submit(%s) = false", job.FileID)
+		}
+	}
+	// Shutdown resumes a paused pool so the queue drains.
+	p.Shutdown()
+
+	if got := <-results; len(got) != len(jobs) {
+		t.Fatalf("got %d results, want %d", len(got), len(jobs))
+	}
+}
+
+func TestPoolCancelDuringProcessingNoLeak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	// Hold the only file slot so both jobs block mid-processing.
+	limiter := fdlimit.New(1)
+	if err := limiter.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	defer limiter.Release(1)
+
+	p := NewPool(2, testLogger(), Config{FileLimiter: limiter})
+	p.Start()
+	results := collect(p)
+
+	jobs := testJobs(t, 2)
+	for _, job := range jobs {
+		p.Submit(job)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for limiter.Stats().Waiting < int64(len(jobs)) {
+		if time.Now().After(deadline) {
+			t.Fatal("jobs never started processing")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	p.Cancel()
+	p.Shutdown()
+
+	got := <-results
+	if len(got) != len(jobs) {
+		t.Fatalf("got %d results, want %d", len(got), len(jobs))
+	}
+	for _, res := range got {
+		if res.Err == nil || !res.Abandoned {
+			t.Errorf("%s: got err %v, abandoned %v; want an abandoned cancellation", res.FileID, res.Err, res.Abandoned)
+		}
+	}
+}
+
+func TestPoolAbandonsJobWhenRenewFails(t *testing.T) {
+	p := NewPool(1, testLogger(), Config{})
+	p.Start()
+	results := collect(p)
+
+	job := testJobs(t, 1)[0]
+	job.Renew = func(context.Context) error { return errors.New("claim lost") }
+	p.Submit(job)
+	p.Shutdown()
+
+	got := <-results
+	if len(got) != 1 || !got[0].Abandoned || got[0].Err == nil {
+		t.Fatalf("got %+v, want one abandoned result", got)
+	}
+	if got[0].Hash != "" {
+		t.Error("job was processed after its claim renewal failed")
+	}
+}
@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"os"
+)
+
+// Priority is the queue lane a job waits in. Workers always take the next
+// job from the highest-priority lane that has one.
+type Priority int
+
+const (
+	PriorityHigh   Priority = iota // small files, done while the client waits
+	PriorityNormal                 // everything between the thresholds
+	PriorityLow                    // large files that would hold up the rest
+	numPriorities
+)
+
+// String returns the lane name used in logs and /admin/pool.
+func (pr Priority) String() string {
+	switch pr {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// priorityFor picks a lane by file size: at most cfg.SmallFileThreshold
+// bytes is high priority, at least cfg.LargeFileThreshold bytes is low.
+// Without thresholds, or when the file cannot be stat'ed, the job goes to
+// the normal lane and processing reports any error as usual.
+func (p *Pool) priorityFor(job Job) Priority {
+	if p.cfg.SmallFileThreshold <= 0 && p.cfg.LargeFileThreshold <= 0 {
+		return PriorityNormal
+	}
+	fi, err := os.Stat(job.FilePath)
+	if err != nil {
+		return PriorityNormal
+	}
+	switch size := fi.Size(); {
+	case p.cfg.SmallFileThreshold > 0 && size <= p.cfg.SmallFileThreshold:
+		return PriorityHigh
+	case p.cfg.LargeFileThreshold > 0 && size >= p.cfg.LargeFileThreshold:
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// nextJob takes a job from the highest-priority non-empty lane, blocking
+// until any lane has one. While the pool is paused it takes nothing, and a
+// Pause wakes it from waiting. lanes is the worker's own view of the queue: a
+// lane found closed and drained is set to nil so it is skipped from then on.
+// It returns false once every lane is closed and drained or the pool is
+// cancelled.
+func (p *Pool) nextJob(lanes *[numPriorities]chan Job) (Job, bool) {
+	for {
+		if !p.waitResumed() || p.ctx.Err() != nil {
+			return Job{}, false
+		}
+		pausing := p.pauseSignal()
+
+		open := false
+		for i, lane := range lanes {
+			if lane == nil {
+				continue
+			}
+			select {
+			case job, ok := <-lane:
+				if ok {
+					return job, true
+				}
+				lanes[i] = nil
+			default:
+				open = true
+			}
+		}
+		if !open {
+			return Job{}, false
+		}
+
+		// Every lane is empty: wait for whichever fills first. Receiving from
+		// a nil lane blocks forever, so closed lanes drop out of the select.
+		var (
+			job  Job
+			ok   bool
+			from Priority
+		)
+		select {
+		case job, ok = <-lanes[PriorityHigh]:
+			from = PriorityHigh
+		case job, ok = <-lanes[PriorityNormal]:
+			from = PriorityNormal
+		case job, ok = <-lanes[PriorityLow]:
+			from = PriorityLow
+		case <-pausing:
+			continue
+		case <-p.ctx.Done():
+			return Job{}, false
+		}
+		if ok {
+			return job, true
+		}
+		lanes[from] = nil
+	}
+}
+
+// LaneDepths returns the number of queued jobs per priority lane.
+func (p *Pool) LaneDepths() map[string]int {
+	depths := make(map[string]int, numPriorities)
+	for pr, lane := range p.lanes {
+		depths[Priority(pr).String()] = len(lane)
+	}
+	return depths
+}
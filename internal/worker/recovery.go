@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// RecoveryProgress tracks the startup re-submission of jobs a previous
+// process accepted but never finished. Counters are updated by the recovery
+// goroutine and read concurrently by Snapshot.
+type RecoveryProgress struct {
+	total       atomic.Int64
+	resubmitted atomic.Int64
+	missing     atomic.Int64
+	errors      atomic.Int64
+	done        atomic.Bool
+}
+
+// RecoverySnapshot is a point-in-time view of RecoveryProgress.
+type RecoverySnapshot struct {
+	Total       int64 `json:"total"`
+	Resubmitted int64 `json:"resubmitted"`
+	Missing     int64 `json:"missing"`
+	Errors      int64 `json:"errors"`
+	Remaining   int64 `json:"remaining"`
+	Done        bool  `json:"done"`
+}
+
+// SetTotal records how many jobs recovery found.
+func (r *RecoveryProgress) SetTotal(n int) { r.total.Store(int64(n)) }
+
+// Resubmitted, Missing and Error account for one recovered job each.
+func (r *RecoveryProgress) Resubmitted() { r.resubmitted.Add(1) }
+func (r *RecoveryProgress) Missing()     { r.missing.Add(1) }
+func (r *RecoveryProgress) Error()       { r.errors.Add(1) }
+
+// Finish marks recovery as over, whether it completed or was cut short.
+func (r *RecoveryProgress) Finish() { r.done.Store(true) }
+
+// Snapshot returns the current counters.
+func (r *RecoveryProgress) Snapshot() RecoverySnapshot {
+	s := RecoverySnapshot{
+		Total:       r.total.Load(),
+		Resubmitted: r.resubmitted.Load(),
+		Missing:     r.missing.Load(),
+		Errors:      r.errors.Load(),
+		Done:        r.done.Load(),
+	}
+	s.Remaining = max(s.Total-s.Resubmitted-s.Missing-s.Errors, 0)
+	return s
+}
+
+// WaitQueueBelow blocks until fewer than limit jobs are queued, polling every
+// interval. It lets a bulk submitter such as recovery leave room in the queue
+// for live uploads instead of filling it. It returns false if ctx is
+// cancelled first. A limit of 0 or less never waits; a non-positive interval
+// polls every 100ms.
+func (p *Pool) WaitQueueBelow(ctx context.Context, limit int, interval time.Duration) bool {
+	if limit <= 0 {
+		return ctx.Err() == nil
+	}
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for p.QueueDepth() >= limit {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
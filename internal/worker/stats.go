@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// workerCounters tracks per-worker activity. All fields are updated atomically
+// by the owning worker and read concurrently by Stats.
+type workerCounters struct {
+	processed    atomic.Int64
+	failures     atomic.Int64
+	totalLatency atomic.Int64 // nanoseconds
+	lastActive   atomic.Int64 // unix nanoseconds, 0 if never active
+}
+
+// record accounts for one finished job.
+func (c *workerCounters) record(latency time.Duration, failed bool) {
+	c.processed.Add(1)
+	if failed {
+		c.failures.Add(1)
+	}
+	c.totalLatency.Add(int64(latency))
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// reset zeroes all counters.
+func (c *workerCounters) reset() {
+	c.processed.Store(0)
+	c.failures.Store(0)
+	c.totalLatency.Store(0)
+	c.lastActive.Store(0)
+}
+
+// WorkerStats is a point-in-time snapshot of one worker's counters.
+type WorkerStats struct {
+	WorkerID     int           `json:"worker_id"`
+	Processed    int64         `json:"processed"` // includes failures
+	Failures     int64         `json:"failures"`
+	TotalLatency time.Duration `json:"total_latency_ns"`
+	AvgLatency   time.Duration `json:"avg_latency_ns"`
+	LastActive   time.Time     `json:"last_active,omitempty"`
+}
+
+// Stats returns a snapshot of every worker's counters, ordered by worker ID.
+func (p *Pool) Stats() []WorkerStats {
+	out := make([]WorkerStats, len(p.stats))
+	for i := range p.stats {
+		c := &p.stats[i]
+		s := WorkerStats{
+			WorkerID:     i,
+			Processed:    c.processed.Load(),
+			Failures:     c.failures.Load(),
+			TotalLatency: time.Duration(c.totalLatency.Load()),
+		}
+		if s.Processed > 0 {
+			s.AvgLatency = s.TotalLatency / time.Duration(s.Processed)
+		}
+		if ns := c.lastActive.Load(); ns > 0 {
+			s.LastActive = time.Unix(0, ns)
+		}
+		out[i] = s
+	}
+	return out
+}
+
+// ResetStats zeroes the counters of every worker, the rolling metrics and the
+// latency histogram.
+func (p *Pool) ResetStats() {
+	for i := range p.stats {
+		p.stats[i].reset()
+	}
+	p.metrics.reset(time.Now())
+	p.latency.reset()
+}
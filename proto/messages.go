@@ -5,9 +5,14 @@ package proto
 
 // RegisterFileRequest is the request for RegisterFile.
 type RegisterFileRequest struct {
-	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	FilePath string `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
-	Status   string `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Id           string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	FilePath     string `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Status       string `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Owner        string `protobuf:"bytes,4,opt,name=owner,proto3" json:"owner,omitempty"`
+	Size         int64  `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
+	ExpiresAt    int64  `protobuf:"varint,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	OriginalName string `protobuf:"bytes,7,opt,name=original_name,json=originalName,proto3" json:"original_name,omitempty"`
+	MetadataJson string `protobuf:"bytes,8,opt,name=metadata_json,json=metadataJson,proto3" json:"metadata_json,omitempty"`
 }
 
 // RegisterFileResponse is the response for RegisterFile.
@@ -16,6 +21,17 @@ type RegisterFileResponse struct {
 	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
 }
 
+// RegisterFileWithMetadataRequest is the request for RegisterFileWithMetadata.
+type RegisterFileWithMetadataRequest struct {
+	Id       string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	FilePath string            `protobuf:"bytes,2,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
+	Hash     string            `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+	Size     int64             `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Owner    string            `protobuf:"bytes,6,opt,name=owner,proto3" json:"owner,omitempty"`
+	Upsert   bool              `protobuf:"varint,7,opt,name=upsert,proto3" json:"upsert,omitempty"`
+}
+
 // UpdateStatusRequest is the request for UpdateStatus.
 type UpdateStatusRequest struct {
 	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -27,3 +43,17 @@ type UpdateStatusResponse struct {
 	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
 }
+
+// WatchStatusRequest is the request for WatchStatus.
+type WatchStatusRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+// StatusUpdate is one message of the WatchStatus stream.
+type StatusUpdate struct {
+	Id         string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status     string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Event      string `protobuf:"bytes,3,opt,name=event,proto3" json:"event,omitempty"`
+	Detail     string `protobuf:"bytes,4,opt,name=detail,proto3" json:"detail,omitempty"`
+	TimeUnixMs int64  `protobuf:"varint,5,opt,name=time_unix_ms,json=timeUnixMs,proto3" json:"time_unix_ms,omitempty"`
+}
@@ -14,12 +14,28 @@ import (
 type GopherDriveServer interface {
 	RegisterFile(context.Context, *RegisterFileRequest) (*RegisterFileResponse, error)
 	UpdateStatus(context.Context, *UpdateStatusRequest) (*UpdateStatusResponse, error)
+	RegisterFileWithMetadata(context.Context, *RegisterFileWithMetadataRequest) (*RegisterFileResponse, error)
+	WatchStatus(*WatchStatusRequest, GopherDrive_WatchStatusServer) error
 }
 
 // GopherDriveClient is the client-side interface for the MetadataService.
 type GopherDriveClient interface {
 	RegisterFile(ctx context.Context, in *RegisterFileRequest, opts ...grpc.CallOption) (*RegisterFileResponse, error)
 	UpdateStatus(ctx context.Context, in *UpdateStatusRequest, opts ...grpc.CallOption) (*UpdateStatusResponse, error)
+	RegisterFileWithMetadata(ctx context.Context, in *RegisterFileWithMetadataRequest, opts ...grpc.CallOption) (*RegisterFileResponse, error)
+	WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (GopherDrive_WatchStatusClient, error)
+}
+
+// GopherDrive_WatchStatusServer is the server side of a WatchStatus stream.
+type GopherDrive_WatchStatusServer interface {
+	Send(*StatusUpdate) error
+	grpc.ServerStream
+}
+
+// GopherDrive_WatchStatusClient is the client side of a WatchStatus stream.
+type GopherDrive_WatchStatusClient interface {
+	Recv() (*StatusUpdate, error)
+	grpc.ClientStream
 }
 
 // ---- server registration ----
@@ -37,8 +53,18 @@ var ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateStatus",
 			Handler:    _GopherDrive_UpdateStatus_Handler,
 		},
+		{
+			MethodName: "RegisterFileWithMetadata",
+			Handler:    _GopherDrive_RegisterFileWithMetadata_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _GopherDrive_WatchStatus_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/gopherdrive.proto",
 }
 
@@ -63,6 +89,30 @@ func _GopherDrive_UpdateStatus_Handler(srv interface{}, ctx context.Context, dec
 	return srv.(GopherDriveServer).UpdateStatus(ctx, in)
 }
 
+func _GopherDrive_RegisterFileWithMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterFileWithMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(GopherDriveServer).RegisterFileWithMetadata(ctx, in)
+}
+
+func _GopherDrive_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	in := new(WatchStatusRequest)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(GopherDriveServer).WatchStatus(in, &gopherDriveWatchStatusServer{stream})
+}
+
+type gopherDriveWatchStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *gopherDriveWatchStatusServer) Send(m *StatusUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // ---- client implementation ----
 
 type gopherDriveClient struct {
@@ -91,3 +141,39 @@ func (c *gopherDriveClient) UpdateStatus(ctx context.Context, in *UpdateStatusRe
 	}
 	return out, nil
 }
+
+func (c *gopherDriveClient) RegisterFileWithMetadata(ctx context.Context, in *RegisterFileWithMetadataRequest, opts ...grpc.CallOption) (*RegisterFileResponse, error) {
+	out := new(RegisterFileResponse)
+	err := c.cc.Invoke(ctx, "/gopherdrive.MetadataService/RegisterFileWithMetadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gopherDriveClient) WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (GopherDrive_WatchStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ServiceDesc.Streams[0], "/gopherdrive.MetadataService/WatchStatus", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gopherDriveWatchStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type gopherDriveWatchStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *gopherDriveWatchStatusClient) Recv() (*StatusUpdate, error) {
+	m := new(StatusUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -8,5 +8,53 @@ CREATE TABLE IF NOT EXISTS files (
     status    VARCHAR(20)  NOT NULL DEFAULT 'pending',
     file_path VARCHAR(512) NOT NULL,
     created_at TIMESTAMP   DEFAULT CURRENT_TIMESTAMP,
-    metadata   JSON
+    metadata   JSON,
+    owner      VARCHAR(128) NOT NULL DEFAULT 'shared',
+    expires_at DATETIME     NULL,
+    original_name VARCHAR(255) NOT NULL DEFAULT '',
+    mime_type  VARCHAR(255) NOT NULL DEFAULT '',
+    failure_reason VARCHAR(1024) NOT NULL DEFAULT '',
+    epoch      BIGINT       NOT NULL DEFAULT 0,
+    seq        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    UNIQUE INDEX uq_files_seq (seq),
+    INDEX idx_files_owner (owner),
+    INDEX idx_files_expires_at (expires_at),
+    INDEX idx_files_hash (hash),
+    INDEX idx_files_mime_type (mime_type),
+    INDEX idx_files_created_at (created_at)
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id          BIGINT       AUTO_INCREMENT PRIMARY KEY,
+    file_id     VARCHAR(36)  NOT NULL,
+    file_path   VARCHAR(512) NOT NULL,
+    extractors  JSON,
+    status      VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    claimed_by  VARCHAR(128) NOT NULL DEFAULT '',
+    claim_token VARCHAR(36)  NOT NULL DEFAULT '',
+    claimed_at  DATETIME     NULL,
+    created_at  TIMESTAMP    DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_jobs_status (status, id),
+    INDEX idx_jobs_claim_token (claim_token)
+);
+
+CREATE TABLE IF NOT EXISTS leader_lock (
+    name       VARCHAR(64)  PRIMARY KEY,
+    holder     VARCHAR(128) NOT NULL,
+    expires_at DATETIME(3)  NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+    name       VARCHAR(64)  PRIMARY KEY,
+    value      TEXT         NOT NULL,
+    updated_at TIMESTAMP    DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS file_metadata_items (
+    file_id  VARCHAR(36)  NOT NULL,
+    item_key VARCHAR(128) NOT NULL,
+    idx      INT          NOT NULL,
+    value    JSON         NOT NULL,
+    PRIMARY KEY (file_id, item_key, idx),
+    CONSTRAINT fk_items_file FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE CASCADE
 );
@@ -0,0 +1,5 @@
+-- Record which client uploaded each file, for quotas and access checks.
+-- Existing files become shared.
+ALTER TABLE files
+    ADD COLUMN owner VARCHAR(128) NOT NULL DEFAULT 'shared',
+    ADD INDEX idx_files_owner (owner);
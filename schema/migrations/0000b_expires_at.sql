@@ -0,0 +1,4 @@
+-- Optional per-file expiry, swept by the janitor. NULL never expires.
+ALTER TABLE files
+    ADD COLUMN expires_at DATETIME NULL,
+    ADD INDEX idx_files_expires_at (expires_at);
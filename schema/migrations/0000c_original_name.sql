@@ -0,0 +1,3 @@
+-- Client-supplied filename, used for Content-Disposition on download.
+ALTER TABLE files
+    ADD COLUMN original_name VARCHAR(255) NOT NULL DEFAULT '';
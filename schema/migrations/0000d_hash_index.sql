@@ -0,0 +1,3 @@
+-- Index hash for POST /files/stage lookups of already-stored content.
+ALTER TABLE files
+    ADD INDEX idx_files_hash (hash);
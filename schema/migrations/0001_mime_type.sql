@@ -0,0 +1,9 @@
+-- Denormalize metadata.mime_type into an indexed column for filtering.
+ALTER TABLE files
+    ADD COLUMN mime_type VARCHAR(255) NOT NULL DEFAULT '',
+    ADD INDEX idx_files_mime_type (mime_type);
+
+UPDATE files
+SET mime_type = LOWER(TRIM(SUBSTRING_INDEX(
+    COALESCE(JSON_UNQUOTE(JSON_EXTRACT(metadata, '$.mime_type')), ''), ';', 1)))
+WHERE metadata IS NOT NULL;
@@ -0,0 +1,14 @@
+-- Durable job queue used when QUEUE_BACKEND=db.
+CREATE TABLE IF NOT EXISTS jobs (
+    id          BIGINT       AUTO_INCREMENT PRIMARY KEY,
+    file_id     VARCHAR(36)  NOT NULL,
+    file_path   VARCHAR(512) NOT NULL,
+    extractors  JSON,
+    status      VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    claimed_by  VARCHAR(128) NOT NULL DEFAULT '',
+    claim_token VARCHAR(36)  NOT NULL DEFAULT '',
+    claimed_at  DATETIME     NULL,
+    created_at  TIMESTAMP    DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_jobs_status (status, id),
+    INDEX idx_jobs_claim_token (claim_token)
+);
@@ -0,0 +1,6 @@
+-- Lease row for leader election (LEADER_ELECTION=true).
+CREATE TABLE IF NOT EXISTS leader_lock (
+    name       VARCHAR(64)  PRIMARY KEY,
+    holder     VARCHAR(128) NOT NULL,
+    expires_at DATETIME(3)  NOT NULL
+);
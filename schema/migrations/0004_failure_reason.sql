@@ -0,0 +1,3 @@
+-- Keep the last processing error so clients can see why a file failed.
+ALTER TABLE files
+    ADD COLUMN failure_reason VARCHAR(1024) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Runtime settings managed through /admin/settings.
+CREATE TABLE IF NOT EXISTS settings (
+    name       VARCHAR(64)  PRIMARY KEY,
+    value      TEXT         NOT NULL,
+    updated_at TIMESTAMP    DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
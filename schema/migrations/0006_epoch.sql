@@ -0,0 +1,4 @@
+-- Processing generation: results apply only while the record's epoch still
+-- matches the one their job started with.
+ALTER TABLE files
+    ADD COLUMN epoch BIGINT NOT NULL DEFAULT 0;
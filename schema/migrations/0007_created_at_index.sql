@@ -0,0 +1,3 @@
+-- Index created_at for date-range listings, ingest stats and purges.
+ALTER TABLE files
+    ADD INDEX idx_files_created_at (created_at);
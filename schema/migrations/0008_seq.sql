@@ -0,0 +1,6 @@
+-- Human-friendly sequence number per file, assigned by MySQL at insert.
+-- Values are unique but may have gaps (rolled-back inserts, upserts).
+-- Existing rows are numbered in primary key order.
+ALTER TABLE files
+    ADD COLUMN seq BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    ADD UNIQUE INDEX uq_files_seq (seq);
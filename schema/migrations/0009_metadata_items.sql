@@ -0,0 +1,10 @@
+-- List metadata too long for the metadata column (archive entries and the
+-- like), one row per element. Rows go with their file.
+CREATE TABLE IF NOT EXISTS file_metadata_items (
+    file_id  VARCHAR(36)  NOT NULL,
+    item_key VARCHAR(128) NOT NULL,
+    idx      INT          NOT NULL,
+    value    JSON         NOT NULL,
+    PRIMARY KEY (file_id, item_key, idx),
+    CONSTRAINT fk_items_file FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE CASCADE
+);
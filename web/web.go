@@ -0,0 +1,9 @@
+// Package web embeds the dashboard assets so the binary is self-contained.
+package web
+
+import "embed"
+
+// FS holds the dashboard served at "/" when no on-disk directory overrides it.
+//
+//go:embed index.html
+var FS embed.FS